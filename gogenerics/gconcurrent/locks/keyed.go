@@ -0,0 +1,113 @@
+// Package locks provides per-key locks and a weighted semaphore.
+package locks
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// KeyedMutex is a set of mutual exclusion locks, one per key, e.g. to allow
+	// only one refresh per user ID at a time.
+	//
+	// The lock of a key is created on demand and reference-counted by the goroutines
+	// holding or waiting for it, it's dropped once the last of them unlocks,
+	// so the memory doesn't grow with the number of keys ever locked.
+	//
+	// The zero KeyedMutex is ready for use. A KeyedMutex must not be copied after first use.
+	KeyedMutex[K comparable] struct {
+		mu sync.Mutex
+		m  map[K]*keyedLock
+	}
+
+	keyedLock struct {
+		// ch holds a token while the key is locked.
+		ch   chan struct{}
+		refs int
+	}
+)
+
+// Lock locks key. If the key is already locked, Lock blocks until it's unlocked.
+func (km *KeyedMutex[K]) Lock(key K) {
+	km.acquire(key).ch <- struct{}{}
+}
+
+// LockContext is like Lock, but it returns ctx.Err() without locking if ctx is done first.
+func (km *KeyedMutex[K]) LockContext(ctx context.Context, key K) error {
+	l := km.acquire(key)
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		km.release(key, l)
+		return ctx.Err()
+	}
+}
+
+// TryLock tries to lock key and reports whether it succeeded.
+func (km *KeyedMutex[K]) TryLock(key K) bool {
+	l := km.acquire(key)
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	default:
+		km.release(key, l)
+		return false
+	}
+}
+
+// Unlock unlocks key. It's a run-time error if key is not locked on entry to Unlock.
+//
+// Like sync.Mutex, a locked key is not associated with a particular goroutine,
+// it's allowed for one goroutine to lock a key and then arrange for another goroutine to unlock it.
+func (km *KeyedMutex[K]) Unlock(key K) {
+	km.mu.Lock()
+	l, ok := km.m[key]
+	km.mu.Unlock()
+	if !ok {
+		panic("locks: unlock of unlocked key")
+	}
+
+	select {
+	case <-l.ch:
+	default:
+		panic("locks: unlock of unlocked key")
+	}
+	km.release(key, l)
+}
+
+// Len returns the number of keys locked or waited for.
+func (km *KeyedMutex[K]) Len() int {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	return len(km.m)
+}
+
+// acquire returns the lock of key with a reference taken.
+func (km *KeyedMutex[K]) acquire(key K) *keyedLock {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	if km.m == nil {
+		km.m = make(map[K]*keyedLock)
+	}
+	l, ok := km.m[key]
+	if !ok {
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		km.m[key] = l
+	}
+	l.refs++
+
+	return l
+}
+
+// release drops a reference to the lock of key, and the lock itself if it's the last one.
+func (km *KeyedMutex[K]) release(key K, l *keyedLock) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	l.refs--
+	if l.refs == 0 {
+		delete(km.m, key)
+	}
+}
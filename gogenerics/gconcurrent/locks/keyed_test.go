@@ -0,0 +1,70 @@
+package locks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	assert := assert.New(t)
+
+	var km KeyedMutex[string]
+	km.Lock("a")
+	assert.False(km.TryLock("a"))
+	assert.True(km.TryLock("b"))
+	assert.Equal(2, km.Len())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(km.LockContext(ctx, "a"), context.DeadlineExceeded)
+
+	locked := make(chan struct{})
+	go func() {
+		assert.Nil(km.LockContext(context.Background(), "a"))
+		close(locked)
+	}()
+	km.Unlock("a")
+	<-locked
+	km.Unlock("a")
+	km.Unlock("b")
+
+	// the locks are dropped once unlocked
+	assert.Equal(0, km.Len())
+	assert.Panics(func() { km.Unlock("a") })
+}
+
+func TestKeyedMutexConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	const keys, goroutines, rounds = 4, 16, 500
+	var (
+		km       KeyedMutex[int]
+		counters [keys]int
+		wg       sync.WaitGroup
+	)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				key := (g + i) % keys
+				km.Lock(key)
+				// guarded by the lock of key only
+				counters[key]++
+				km.Unlock(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var total int
+	for _, c := range counters {
+		total += c
+	}
+	assert.Equal(goroutines*rounds, total)
+	assert.Equal(0, km.Len())
+}
@@ -0,0 +1,126 @@
+package locks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/miniLCT/gosb/gogenerics/gcontainers/glist"
+)
+
+type (
+	// Semaphore is a weighted semaphore, it limits the total weight of the resources held at a time.
+	//
+	// The waiters are served in FIFO order: a large Acquire blocks the smaller ones
+	// behind it, so it's never starved by a stream of small ones.
+	// A Semaphore must be created with NewSemaphore.
+	Semaphore struct {
+		mu      sync.Mutex
+		size    int64
+		cur     int64
+		waiters glist.List[semWaiter]
+	}
+
+	semWaiter struct {
+		n     int64
+		ready chan struct{} // closed when the semaphore is acquired
+	}
+)
+
+// NewSemaphore returns a Semaphore with the given maximum combined weight.
+func NewSemaphore(n int64) *Semaphore {
+	return &Semaphore{size: n}
+}
+
+// Acquire acquires the semaphore with a weight of n, blocking until resources
+// are available or ctx is done. On success, it returns nil. On failure, it returns
+// ctx.Err() and leaves the semaphore unchanged.
+//
+// If ctx is already done, Acquire may still succeed without blocking.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	if n > s.size {
+		// it can never succeed, wait for ctx
+		s.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(semWaiter{n: n, ready: ready})
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// acquired after ctx is done, keep it rather than undoing it
+			s.mu.Unlock()
+			return nil
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// the waiters behind the front one may fit now
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// TryAcquire acquires the semaphore with a weight of n without blocking.
+// On success, it returns true. On failure, it returns false and leaves the semaphore unchanged.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release releases the semaphore with a weight of n.
+func (s *Semaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	if s.cur < 0 {
+		panic("locks: semaphore released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// notifyWaiters wakes up the waiters in FIFO order as long as they fit.
+func (s *Semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+
+		w := next.Value
+		if s.size-s.cur < w.n {
+			// Not enough tokens for the next waiter. We could keep going (to try to
+			// find a waiter with a smaller request), but under load that could cause
+			// starvation for large requests.
+			return
+		}
+
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,137 @@
+package locks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSemaphore(3)
+	ctx := context.Background()
+	assert.Nil(s.Acquire(ctx, 2))
+	assert.True(s.TryAcquire(1))
+	assert.False(s.TryAcquire(1))
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(s.Acquire(timeout, 1), context.DeadlineExceeded)
+	// more than the size never succeeds
+	assert.ErrorIs(s.Acquire(timeout, 4), context.DeadlineExceeded)
+
+	s.Release(3)
+	assert.True(s.TryAcquire(3))
+	s.Release(3)
+	assert.Panics(func() { s.Release(1) })
+}
+
+func TestSemaphoreFIFO(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSemaphore(2)
+	ctx := context.Background()
+	assert.Nil(s.Acquire(ctx, 2))
+
+	// a large waiter at the front blocks the small ones behind it
+	large := make(chan struct{})
+	go func() {
+		assert.Nil(s.Acquire(ctx, 2))
+		close(large)
+	}()
+	waitForWaiters(s, 1)
+	small := make(chan struct{})
+	go func() {
+		assert.Nil(s.Acquire(ctx, 1))
+		close(small)
+	}()
+	waitForWaiters(s, 2)
+	assert.False(s.TryAcquire(1))
+
+	s.Release(1)
+	select {
+	case <-small:
+		assert.Fail("small waiter jumped the queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.Release(1)
+	<-large
+	s.Release(2)
+	<-small
+	s.Release(1)
+}
+
+func TestSemaphoreCancelFront(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSemaphore(2)
+	assert.Nil(s.Acquire(context.Background(), 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		assert.ErrorIs(s.Acquire(ctx, 2), context.Canceled)
+	}()
+	waitForWaiters(s, 1)
+	small := make(chan struct{})
+	go func() {
+		assert.Nil(s.Acquire(context.Background(), 1))
+		close(small)
+	}()
+	waitForWaiters(s, 2)
+
+	// the waiter behind the cancelled front one is woken up
+	cancel()
+	<-small
+}
+
+func TestSemaphoreConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	const size = 5
+	var (
+		s       = NewSemaphore(size)
+		held    int64
+		maxHeld int64
+		wg      sync.WaitGroup
+	)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			n := int64(g%3 + 1)
+			for i := 0; i < 100; i++ {
+				assert.Nil(s.Acquire(context.Background(), n))
+				cur := atomic.AddInt64(&held, n)
+				for {
+					old := atomic.LoadInt64(&maxHeld)
+					if cur <= old || atomic.CompareAndSwapInt64(&maxHeld, old, cur) {
+						break
+					}
+				}
+				atomic.AddInt64(&held, -n)
+				s.Release(n)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(maxHeld, int64(size))
+	assert.True(s.TryAcquire(size))
+}
+
+func waitForWaiters(s *Semaphore, n int) {
+	for {
+		s.mu.Lock()
+		l := s.waiters.Len()
+		s.mu.Unlock()
+		if l == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
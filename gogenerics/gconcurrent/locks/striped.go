@@ -0,0 +1,114 @@
+package locks
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/miniLCT/gosb/gogenerics/gconcurrent/mapx"
+)
+
+// cacheLineSize is used to pad the stripes, so that two locks don't share a cache line.
+const cacheLineSize = 64
+
+type (
+	// Striped is a fixed array of RWMutexes, a key is guarded by the lock its hash picks.
+	//
+	// Unlike KeyedMutex, it never allocates on locking, while different keys may share a lock.
+	// A Striped must be created with NewStriped.
+	Striped[K comparable] struct {
+		hasher  mapx.Hasher[K]
+		mask    uint64
+		stripes []stripe
+	}
+
+	stripe struct {
+		sync.RWMutex
+		_ [cacheLineSize - 24]byte
+	}
+)
+
+// NewStriped returns a Striped with the given number of locks, rounded up to a power of 2,
+// and the hasher of the keys. stripes defaults to 4 per CPU, and hasher defaults to mapx.Hash,
+// which hashes pointer keys by address, so a key keeps its lock while what it points to changes.
+func NewStriped[K comparable](stripes int, hasher mapx.Hasher[K]) *Striped[K] {
+	if stripes <= 0 {
+		stripes = 4 * runtime.GOMAXPROCS(0)
+	}
+	if hasher == nil {
+		hasher = mapx.Hash[K]
+	}
+
+	n := 1
+	for n < stripes {
+		n <<= 1
+	}
+	return &Striped[K]{
+		hasher:  hasher,
+		mask:    uint64(n - 1),
+		stripes: make([]stripe, n),
+	}
+}
+
+func (s *Striped[K]) index(key K) int {
+	return int(s.hasher(key) & s.mask)
+}
+
+// Get returns the lock of key.
+func (s *Striped[K]) Get(key K) *sync.RWMutex {
+	return &s.stripes[s.index(key)].RWMutex
+}
+
+// Lock locks key for writing.
+func (s *Striped[K]) Lock(key K) {
+	s.Get(key).Lock()
+}
+
+// Unlock unlocks key for writing.
+func (s *Striped[K]) Unlock(key K) {
+	s.Get(key).Unlock()
+}
+
+// RLock locks key for reading.
+func (s *Striped[K]) RLock(key K) {
+	s.Get(key).RLock()
+}
+
+// RUnlock undoes a single RLock call of key.
+func (s *Striped[K]) RUnlock(key K) {
+	s.Get(key).RUnlock()
+}
+
+// LockKeys locks all the keys for writing, and returns the func to unlock them.
+// The locks are taken in a fixed order, so two goroutines locking overlapping keys don't deadlock.
+func (s *Striped[K]) LockKeys(keys ...K) (unlock func()) {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, s.index(key))
+	}
+	sort.Ints(indexes)
+
+	// keys sharing a lock take it once
+	n := 0
+	for i, idx := range indexes {
+		if i == 0 || idx != indexes[n-1] {
+			indexes[n] = idx
+			n++
+		}
+	}
+	indexes = indexes[:n]
+
+	for _, idx := range indexes {
+		s.stripes[idx].Lock()
+	}
+	return func() {
+		for i := len(indexes) - 1; i >= 0; i-- {
+			s.stripes[indexes[i]].Unlock()
+		}
+	}
+}
+
+// Len returns the number of locks.
+func (s *Striped[K]) Len() int {
+	return len(s.stripes)
+}
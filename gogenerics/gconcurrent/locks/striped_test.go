@@ -0,0 +1,80 @@
+package locks
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStriped(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewStriped[int](3, func(key int) uint64 {
+		return uint64(key)
+	})
+	assert.Equal(4, s.Len())
+	assert.Same(s.Get(1), s.Get(5))
+	assert.NotSame(s.Get(1), s.Get(2))
+
+	s.RLock(1)
+	s.RLock(5)
+	assert.False(s.Get(1).TryLock())
+	s.RUnlock(5)
+	s.RUnlock(1)
+
+	unlock := s.LockKeys(1, 5, 2, 1)
+	assert.False(s.Get(1).TryRLock())
+	assert.False(s.Get(2).TryRLock())
+	assert.True(s.Get(3).TryLock())
+	s.Unlock(3)
+	unlock()
+	assert.True(s.Get(1).TryLock())
+	s.Unlock(1)
+}
+
+func TestStripedPointerKey(t *testing.T) {
+	assert := assert.New(t)
+
+	type account struct {
+		balance int
+	}
+	s := NewStriped[*account](1024, nil)
+	a := &account{}
+	l := s.Get(a)
+	for i := 0; i < 100; i++ {
+		s.Lock(a)
+		a.balance++
+		s.Unlock(a)
+		assert.Same(l, s.Get(a))
+	}
+}
+
+func TestStripedConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	const goroutines, rounds = 8, 500
+	var (
+		s        = NewStriped[string](0, nil)
+		balances = map[string]int{"a": 0, "b": 0, "c": 0}
+		names    = []string{"a", "b", "c"}
+		wg       sync.WaitGroup
+	)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				from, to := names[(g+i)%3], names[(g+i+1)%3]
+				// opposite transfers lock the same keys in the same order
+				unlock := s.LockKeys(from, to)
+				balances[from]--
+				balances[to]++
+				unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Equal(0, balances["a"]+balances["b"]+balances["c"])
+}
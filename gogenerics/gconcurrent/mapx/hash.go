@@ -0,0 +1,129 @@
+package mapx
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+// Hasher returns the hash of a key, equal keys must have the same hash.
+type Hasher[K comparable] func(key K) uint64
+
+var hashSeed = maphash.MakeSeed()
+
+// Hash is the default Hasher, with fast paths for strings, integers and floats.
+//
+// Other keys are hashed the way == compares them: pointers, channels and unsafe.Pointers by
+// address, never by what they point to, +0 and -0 floats alike, and structs, arrays and
+// interfaces field by field. It's done with reflect, so a Hasher for them is faster.
+func Hash[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(hashSeed, k)
+	case int:
+		return mix64(uint64(k))
+	case int8:
+		return mix64(uint64(k))
+	case int16:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint8:
+		return mix64(uint64(k))
+	case uint16:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uintptr:
+		return mix64(uint64(k))
+	case float64:
+		return mix64(floatBits(k))
+	case float32:
+		return mix64(floatBits(float64(k)))
+	default:
+		var h maphash.Hash
+		h.SetSeed(hashSeed)
+		writeValue(&h, reflect.ValueOf(k))
+		return h.Sum64()
+	}
+}
+
+// floatBits returns the bits of f, +0 and -0 are equal keys.
+func floatBits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+// writeValue writes the parts of v that == compares to h.
+func writeValue(h *maphash.Hash, v reflect.Value) {
+	var buf [8]byte
+	writeUint64 := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		_, _ = h.Write(buf[:])
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		// the nil interface
+		_ = h.WriteByte(0)
+	case reflect.Bool:
+		if v.Bool() {
+			_ = h.WriteByte(1)
+		} else {
+			_ = h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint64(floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeUint64(floatBits(real(c)))
+		writeUint64(floatBits(imag(c)))
+	case reflect.String:
+		_, _ = h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint64(uint64(v.Pointer()))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			writeValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			// == ignores the blank fields
+			if t.Field(i).Name != "_" {
+				writeValue(h, v.Field(i))
+			}
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			_ = h.WriteByte(0)
+			return
+		}
+		// equal interfaces hold the same dynamic type
+		_, _ = h.WriteString(v.Elem().Type().String())
+		writeValue(h, v.Elem())
+	}
+}
+
+// mix64 is the finalizer of splitmix64, it spreads the bits of integer keys.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,263 @@
+package mapx
+
+import (
+	"runtime"
+	"sync"
+
+	gsync "github.com/miniLCT/gosb/gogenerics/gconcurrent/sync"
+)
+
+// ComputeOp tells Compute what to do with the computed value, it's the same type as
+// gconcurrent/sync.ComputeOp, so that a Compute func works with both maps.
+type ComputeOp = gsync.ComputeOp
+
+const (
+	// UpdateOp stores the computed value.
+	UpdateOp = gsync.UpdateOp
+	// DeleteOp deletes the key.
+	DeleteOp = gsync.DeleteOp
+	// CancelOp leaves the map unchanged.
+	CancelOp = gsync.CancelOp
+)
+
+// cacheLineSize is used to pad the shards, so that the locks of two shards
+// don't share a cache line.
+const cacheLineSize = 64
+
+type (
+	// Map is a concurrent map split into shards by the hash of the keys,
+	// every shard is a Go map guarded by its own RWMutex.
+	//
+	// Unlike gconcurrent/sync.Map, which is optimized for stable keys that are read
+	// far more than written, Map performs steadily under write-heavy workloads
+	// as long as the writes are spread over the shards.
+	//
+	// A Map must be created with New or NewWithHasher, and must not be copied after first use.
+	Map[K comparable, V any] struct {
+		hasher Hasher[K]
+		mask   uint64
+		shards []shard[K, V]
+	}
+
+	shard[K comparable, V any] struct {
+		mu sync.RWMutex
+		m  map[K]V
+		_  [cacheLineSize - 32]byte
+	}
+
+	entry[K comparable, V any] struct {
+		key   K
+		value V
+	}
+)
+
+// New returns an empty Map hashing the keys with Hash, with 4 shards per CPU.
+func New[K comparable, V any]() *Map[K, V] {
+	return NewWithHasher[K, V](0, nil)
+}
+
+// NewWithHasher returns an empty Map with the given number of shards, rounded up to a power of 2,
+// and the hasher of the keys. shards defaults to 4 per CPU, and hasher defaults to Hash.
+func NewWithHasher[K comparable, V any](shards int, hasher Hasher[K]) *Map[K, V] {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	if hasher == nil {
+		hasher = Hash[K]
+	}
+
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	m := &Map[K, V]{
+		hasher: hasher,
+		mask:   uint64(n - 1),
+		shards: make([]shard[K, V], n),
+	}
+	for i := range m.shards {
+		m.shards[i].m = make(map[K]V)
+	}
+
+	return m
+}
+
+func (m *Map[K, V]) shard(key K) *shard[K, V] {
+	return &m.shards[m.hasher(key)&m.mask]
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no
+// value is present. The ok result indicates whether value was found in the map.
+func (m *Map[K, V]) Load(key K) (value V, ok bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	value, ok = s.m[key]
+	s.mu.RUnlock()
+	return
+}
+
+// Store sets the value for a key.
+func (m *Map[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	actual, loaded = s.m[key]
+	s.mu.RUnlock()
+	if loaded {
+		return actual, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if actual, loaded = s.m[key]; loaded {
+		return actual, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	value, loaded = s.m[key]
+	if loaded {
+		delete(s.m, key)
+	}
+	s.mu.Unlock()
+	return
+}
+
+// Delete deletes the value for a key.
+func (m *Map[K, V]) Delete(key K) {
+	s := m.shard(key)
+	s.mu.Lock()
+	delete(s.m, key)
+	s.mu.Unlock()
+}
+
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	previous, loaded = s.m[key]
+	s.m[key] = value
+	s.mu.Unlock()
+	return
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the map is equal to old.
+// The old value must be of a comparable type.
+func (m *Map[K, V]) CompareAndSwap(key K, old, new V) bool {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.m[key]
+	if !ok || any(value) != any(old) {
+		return false
+	}
+	s.m[key] = new
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type.
+//
+// If there is no current value for key in the map, CompareAndDelete
+// returns false (even if the old value is the nil interface value).
+func (m *Map[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.m[key]
+	if !ok || any(value) != any(old) {
+		return false
+	}
+	delete(s.m, key)
+	return true
+}
+
+// Compute calls fn with the current value of key, and stores, deletes or keeps
+// the value as fn tells, atomically. fn is called with the shard of key locked,
+// so it must be fast and must not call the methods of m.
+// It returns the value of key after the call, and whether the key is present.
+func (m *Map[K, V]) Compute(key K, fn func(old V, loaded bool) (newValue V, op ComputeOp)) (actual V, ok bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, loaded := s.m[key]
+	value, op := fn(old, loaded)
+	switch op {
+	case UpdateOp:
+		s.m[key] = value
+		return value, true
+	case DeleteOp:
+		delete(s.m, key)
+		var zero V
+		return zero, false
+	default:
+		return old, loaded
+	}
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// Range visits the shards one by one with a copy of the shard, so f may call any method on m.
+// Range does not correspond to any consistent snapshot of the Map's contents.
+func (m *Map[K, V]) Range(f func(key K, value V) bool) {
+	var entries []entry[K, V]
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		entries = entries[:0]
+		for k, v := range s.m {
+			entries = append(entries, entry[K, V]{key: k, value: v})
+		}
+		s.mu.RUnlock()
+
+		for _, e := range entries {
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of keys in the map, it's not a consistent snapshot under concurrent writes.
+func (m *Map[K, V]) Len() int {
+	var n int
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+
+	return n
+}
+
+// Clear deletes all the keys.
+func (m *Map[K, V]) Clear() {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		s.m = make(map[K]V)
+		s.mu.Unlock()
+	}
+}
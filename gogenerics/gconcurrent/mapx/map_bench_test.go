@@ -0,0 +1,294 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapx
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	gsync "github.com/miniLCT/gosb/gogenerics/gconcurrent/sync"
+)
+
+type bench struct {
+	setup func(*testing.B, mapInterface)
+	perG  func(b *testing.B, pb *testing.PB, i int, m mapInterface)
+}
+
+func benchMap(b *testing.B, bench bench) {
+	for _, newMap := range [...]func() mapInterface{
+		func() mapInterface { return &SyncMap{} },
+		func() mapInterface { return &gsync.Map[int, int]{} },
+		func() mapInterface { return New[int, int]() },
+	} {
+		m := newMap()
+		b.Run(fmt.Sprintf("%T", m), func(b *testing.B) {
+			m = newMap()
+			if bench.setup != nil {
+				bench.setup(b, m)
+			}
+
+			b.ResetTimer()
+
+			var i int64
+			b.RunParallel(func(pb *testing.PB) {
+				id := int(atomic.AddInt64(&i, 1) - 1)
+				bench.perG(b, pb, id*b.N, m)
+			})
+		})
+	}
+}
+
+func BenchmarkLoadMostlyHits(b *testing.B) {
+	const hits, misses = 1023, 1
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface) {
+			for i := 0; i < hits; i++ {
+				m.LoadOrStore(i, i)
+			}
+			// Prime the map to get it into a steady state.
+			for i := 0; i < hits*2; i++ {
+				m.Load(i % hits)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				m.Load(i % (hits + misses))
+			}
+		},
+	})
+}
+
+func BenchmarkLoadOrStoreBalanced(b *testing.B) {
+	const hits, misses = 128, 128
+
+	benchMap(b, bench{
+		setup: func(b *testing.B, m mapInterface) {
+			for i := 0; i < hits; i++ {
+				m.LoadOrStore(i, i)
+			}
+			// Prime the map to get it into a steady state.
+			for i := 0; i < hits*2; i++ {
+				m.Load(i % hits)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				j := i % (hits + misses)
+				if j < hits {
+					if _, ok := m.LoadOrStore(j, i); !ok {
+						b.Fatalf("unexpected miss for %v", j)
+					}
+				} else {
+					if v, loaded := m.LoadOrStore(i, i); loaded {
+						b.Fatalf("failed to store %v: existing value %v", i, v)
+					}
+				}
+			}
+		},
+	})
+}
+
+// BenchmarkStoreUnique stores a new key every time, the dirty map of sync.Map
+// is copied over and over as the new keys keep missing the read map.
+func BenchmarkStoreUnique(b *testing.B) {
+	benchMap(b, bench{
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				m.Store(i, i)
+			}
+		},
+	})
+}
+
+// BenchmarkStoreMostlyHits overwrites the existing keys.
+func BenchmarkStoreMostlyHits(b *testing.B) {
+	const mapSize = 1 << 10
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface) {
+			for i := 0; i < mapSize; i++ {
+				m.Store(i, i)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				m.Store(i%mapSize, i)
+			}
+		},
+	})
+}
+
+// BenchmarkWriteHeavy stores, deletes and loads keys in a bounded key space,
+// with 3 writes per load.
+func BenchmarkWriteHeavy(b *testing.B) {
+	const mapSize = 1 << 12
+
+	benchMap(b, bench{
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				key := (i * 31) % mapSize
+				switch i % 4 {
+				case 0, 1:
+					m.Store(key, i)
+				case 2:
+					m.Delete(key)
+				default:
+					m.Load(key)
+				}
+			}
+		},
+	})
+}
+
+func BenchmarkLoadAndDeleteCollision(b *testing.B) {
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface) {
+			m.LoadOrStore(0, 0)
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				if _, loaded := m.LoadAndDelete(0); loaded {
+					m.Store(0, 0)
+				}
+			}
+		},
+	})
+}
+
+func BenchmarkSwapMostlyHits(b *testing.B) {
+	const hits, misses = 1023, 1
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface) {
+			for i := 0; i < hits; i++ {
+				m.LoadOrStore(i, i)
+			}
+			// Prime the map to get it into a steady state.
+			for i := 0; i < hits*2; i++ {
+				m.Load(i % hits)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				if i%(hits+misses) < hits {
+					v := i % (hits + misses)
+					m.Swap(v, v)
+				} else {
+					m.Swap(i, i)
+					m.Delete(i)
+				}
+			}
+		},
+	})
+}
+
+func BenchmarkRange(b *testing.B) {
+	const mapSize = 1 << 10
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface) {
+			for i := 0; i < mapSize; i++ {
+				m.Store(i, i)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface) {
+			for ; pb.Next(); i++ {
+				m.Range(func(_, _ int) bool { return true })
+			}
+		},
+	})
+}
+
+// below is the code contains reference map implementations for bench.
+
+// mapInterface is the interface Map implements.
+
+type mapInterface interface {
+	Load(int) (int, bool)
+	Store(key, value int)
+	LoadOrStore(key, value int) (actual int, loaded bool)
+	LoadAndDelete(key int) (value int, loaded bool)
+	Delete(int)
+	Swap(key, value int) (previous int, loaded bool)
+	CompareAndSwap(key, old, new int) (swapped bool)
+	CompareAndDelete(key, old int) (deleted bool)
+	Range(func(key, value int) (shouldContinue bool))
+}
+
+var (
+	_ mapInterface = &SyncMap{}
+	_ mapInterface = &gsync.Map[int, int]{}
+	_ mapInterface = &Map[int, int]{}
+)
+
+// SyncMap is an implementation of mapInterface using the standard sync.Map.
+
+type SyncMap struct {
+	dirty sync.Map
+}
+
+func (m *SyncMap) Load(key int) (value int, ok bool) {
+	v, ok := m.dirty.Load(key)
+	if !ok {
+		return 0, false
+	}
+
+	return v.(int), true
+}
+
+func (m *SyncMap) Store(key, value int) {
+	m.dirty.Store(key, value)
+}
+
+func (m *SyncMap) LoadOrStore(key, value int) (actual int, loaded bool) {
+	v, loaded := m.dirty.LoadOrStore(key, value)
+
+	return v.(int), loaded
+}
+
+func (m *SyncMap) Swap(key, value int) (previous int, loaded bool) {
+	v, loaded := m.dirty.Swap(key, value)
+	if !loaded {
+		return 0, false
+	}
+
+	return v.(int), true
+}
+
+func (m *SyncMap) LoadAndDelete(key int) (value int, loaded bool) {
+	v, loaded := m.dirty.LoadAndDelete(key)
+	if !loaded {
+		return 0, false
+	}
+
+	return v.(int), true
+}
+
+func (m *SyncMap) Delete(key int) {
+	m.dirty.Delete(key)
+}
+
+func (m *SyncMap) CompareAndSwap(key, old, new int) (swapped bool) {
+	return m.dirty.CompareAndSwap(key, old, new)
+}
+
+func (m *SyncMap) CompareAndDelete(key, old int) (deleted bool) {
+	return m.dirty.CompareAndDelete(key, old)
+}
+
+func (m *SyncMap) Range(f func(key, value int) (shouldContinue bool)) {
+	m.dirty.Range(func(key, value any) bool {
+		return f(key.(int), value.(int))
+	})
+}
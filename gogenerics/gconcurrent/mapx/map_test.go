@@ -0,0 +1,199 @@
+package mapx
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+
+	gsync "github.com/miniLCT/gosb/gogenerics/gconcurrent/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	assert := assert.New(t)
+
+	m := New[string, int]()
+	_, ok := m.Load("a")
+	assert.False(ok)
+
+	m.Store("a", 1)
+	v, ok := m.Load("a")
+	assert.True(ok)
+	assert.Equal(1, v)
+
+	v, loaded := m.LoadOrStore("a", 2)
+	assert.True(loaded)
+	assert.Equal(1, v)
+	v, loaded = m.LoadOrStore("b", 2)
+	assert.False(loaded)
+	assert.Equal(2, v)
+
+	v, loaded = m.Swap("a", 3)
+	assert.True(loaded)
+	assert.Equal(1, v)
+	assert.False(m.CompareAndSwap("a", 1, 4))
+	assert.True(m.CompareAndSwap("a", 3, 4))
+	assert.False(m.CompareAndDelete("a", 3))
+	assert.True(m.CompareAndDelete("a", 4))
+	assert.False(m.CompareAndSwap("a", 4, 5))
+
+	v, loaded = m.LoadAndDelete("b")
+	assert.True(loaded)
+	assert.Equal(2, v)
+	_, loaded = m.LoadAndDelete("b")
+	assert.False(loaded)
+
+	m.Store("c", 3)
+	m.Delete("c")
+	assert.Equal(0, m.Len())
+}
+
+func TestMapCompute(t *testing.T) {
+	assert := assert.New(t)
+
+	m := New[int, int]()
+	incr := func(old int, loaded bool) (int, ComputeOp) {
+		return old + 1, UpdateOp
+	}
+	v, ok := m.Compute(1, incr)
+	assert.True(ok)
+	assert.Equal(1, v)
+	v, _ = m.Compute(1, incr)
+	assert.Equal(2, v)
+
+	v, ok = m.Compute(1, func(old int, loaded bool) (int, ComputeOp) {
+		return 0, CancelOp
+	})
+	assert.True(ok)
+	assert.Equal(2, v)
+
+	_, ok = m.Compute(1, func(old int, loaded bool) (int, ComputeOp) {
+		return 0, DeleteOp
+	})
+	assert.False(ok)
+	_, ok = m.Load(1)
+	assert.False(ok)
+
+	_, ok = m.Compute(2, func(old int, loaded bool) (int, ComputeOp) {
+		assert.False(loaded)
+		return 0, CancelOp
+	})
+	assert.False(ok)
+	assert.Equal(0, m.Len())
+
+	// a Compute func is shared with gconcurrent/sync.Map
+	var sm gsync.Map[int, int]
+	v, _ = sm.Compute(1, incr)
+	assert.Equal(1, v)
+}
+
+func TestMapRangeAndClear(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewWithHasher[int, int](3, func(key int) uint64 {
+		return uint64(key)
+	})
+	assert.Len(m.shards, 4)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i*i)
+	}
+	assert.Equal(100, m.Len())
+
+	seen := make(map[int]int)
+	m.Range(func(key, value int) bool {
+		// f may call m
+		m.Store(key+1000, value)
+		seen[key] = value
+		return true
+	})
+	assert.GreaterOrEqual(len(seen), 100)
+	for i := 0; i < 100; i++ {
+		assert.Equal(i*i, seen[i])
+	}
+
+	var visited int
+	m.Range(func(key, value int) bool {
+		visited++
+		return visited < 10
+	})
+	assert.Equal(10, visited)
+
+	m.Clear()
+	assert.Equal(0, m.Len())
+}
+
+func TestMapConcurrentCompute(t *testing.T) {
+	assert := assert.New(t)
+
+	m := New[string, int]()
+	var wg sync.WaitGroup
+	procs := runtime.GOMAXPROCS(0)
+	for g := 0; g < procs; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.Compute("counter", func(old int, loaded bool) (int, ComputeOp) {
+					return old + 1, UpdateOp
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, _ := m.Load("counter")
+	assert.Equal(procs*1000, v)
+}
+
+func TestHash(t *testing.T) {
+	assert := assert.New(t)
+
+	type point struct {
+		x, y int
+	}
+	assert.Equal(Hash("gosb"), Hash("gosb"))
+	assert.NotEqual(Hash(1), Hash(2))
+	assert.Equal(Hash(0.0), Hash(math.Copysign(0, -1)))
+	assert.Equal(Hash(point{1, 2}), Hash(point{1, 2}))
+	assert.NotEqual(Hash(point{1, 2}), Hash(point{2, 1}))
+
+	// composite keys are hashed the way == compares them
+	type vec struct {
+		x, y float64
+		_    int
+	}
+	negZero := math.Copysign(0, -1)
+	assert.Equal(Hash(vec{x: 0, y: 1}), Hash(vec{x: negZero, y: 1}))
+	assert.Equal(Hash([2]float32{0, 1}), Hash([2]float32{float32(negZero), 1}))
+	assert.Equal(Hash(any(point{1, 2})), Hash(any(point{1, 2})))
+	assert.Equal(Hash(struct{ v any }{1}), Hash(struct{ v any }{1}))
+	assert.NotEqual(Hash(struct{ v any }{1}), Hash(struct{ v any }{"1"}))
+
+	p, q := &point{1, 2}, &point{1, 2}
+	assert.NotEqual(Hash(p), Hash(q))
+	h := Hash(p)
+	p.x = 3
+	assert.Equal(h, Hash(p))
+	assert.Equal(Hash(struct{ p *point }{p}), Hash(struct{ p *point }{p}))
+}
+
+func TestMapPointerKey(t *testing.T) {
+	assert := assert.New(t)
+
+	type counter struct {
+		n int
+	}
+	m := New[*counter, string]()
+	k := &counter{n: 1}
+	m.Store(k, "a")
+	// the key is the pointer, not what it points to
+	k.n = 2
+	v, ok := m.Load(k)
+	assert.True(ok)
+	assert.Equal("a", v)
+	m.Store(k, "b")
+	assert.Equal(1, m.Len())
+	_, ok = m.Load(&counter{n: 2})
+	assert.False(ok)
+}
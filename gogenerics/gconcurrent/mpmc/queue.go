@@ -0,0 +1,258 @@
+package mpmc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheLineSize is used to pad head and tail, so that producers and consumers
+// don't invalidate each other's cache line of positions. The slots are not padded,
+// the neighbouring ones may share a cache line.
+const cacheLineSize = 64
+
+type (
+	// Queue is a bounded multi-producer multi-consumer FIFO queue, it's a ring
+	// of slots with per-slot sequence numbers (Dmitry Vyukov's bounded MPMC queue).
+	//
+	// TryPush and TryPop are lock-free and never block, Push and Pop wait for
+	// room or items only when the queue is full or empty.
+	//
+	// A Queue must be created with New, and must not be copied after first use.
+	Queue[T any] struct {
+		_    [cacheLineSize]byte
+		tail atomic.Uint64 // next position to push
+		_    [cacheLineSize - 8]byte
+		head atomic.Uint64 // next position to pop
+		_    [cacheLineSize - 8]byte
+
+		mask  uint64
+		slots []slot[T]
+
+		notEmpty waiters
+		notFull  waiters
+	}
+
+	slot[T any] struct {
+		// seq is the position the slot is ready to be pushed at,
+		// or that position+1 once the value is pushed.
+		seq   atomic.Uint64
+		value T
+	}
+
+	// waiters wakes up the goroutines blocked in Push or Pop.
+	waiters struct {
+		n  atomic.Int64
+		mu sync.Mutex
+		ch chan struct{}
+	}
+)
+
+// New returns an empty Queue holding up to capacity items, rounded up to a power of 2 and at least 2.
+func New[T any](capacity int) *Queue[T] {
+	n := 2
+	for n < capacity {
+		n <<= 1
+	}
+	q := &Queue[T]{
+		mask:  uint64(n - 1),
+		slots: make([]slot[T], n),
+	}
+	for i := range q.slots {
+		q.slots[i].seq.Store(uint64(i))
+	}
+
+	return q
+}
+
+// TryPush adds v to the tail of the queue, it returns false if the queue is full.
+func (q *Queue[T]) TryPush(v T) bool {
+	if !q.tryPush(v) {
+		return false
+	}
+	q.notEmpty.broadcast()
+	return true
+}
+
+func (q *Queue[T]) tryPush(v T) bool {
+	pos := q.tail.Load()
+	for {
+		s := &q.slots[pos&q.mask]
+		switch dif := int64(s.seq.Load() - pos); {
+		case dif == 0:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				s.value = v
+				s.seq.Store(pos + 1)
+				return true
+			}
+			pos = q.tail.Load()
+		case dif < 0:
+			// the slot still holds the item pushed a lap ago
+			return false
+		default:
+			// another producer took the position
+			pos = q.tail.Load()
+		}
+	}
+}
+
+// TryPop removes an item from the head of the queue, ok is false if the queue is empty.
+func (q *Queue[T]) TryPop() (v T, ok bool) {
+	if v, ok = q.tryPop(); ok {
+		q.notFull.broadcast()
+	}
+	return
+}
+
+func (q *Queue[T]) tryPop() (v T, ok bool) {
+	pos := q.head.Load()
+	for {
+		s := &q.slots[pos&q.mask]
+		switch dif := int64(s.seq.Load() - (pos + 1)); {
+		case dif == 0:
+			if q.head.CompareAndSwap(pos, pos+1) {
+				v = s.value
+				var zero T
+				s.value = zero
+				// ready to be pushed on the next lap
+				s.seq.Store(pos + q.mask + 1)
+				return v, true
+			}
+			pos = q.head.Load()
+		case dif < 0:
+			// the slot is not pushed yet
+			return v, false
+		default:
+			// another consumer took the position
+			pos = q.head.Load()
+		}
+	}
+}
+
+// Push adds v to the tail of the queue, waiting for room if the queue is full.
+// It returns ctx.Err() if ctx is done before v is pushed.
+func (q *Queue[T]) Push(ctx context.Context, v T) error {
+	for {
+		if q.TryPush(v) {
+			return nil
+		}
+
+		// register before trying again, so that a Pop after the try wakes us up.
+		ch := q.notFull.wait()
+		if q.TryPush(v) {
+			q.notFull.done()
+			return nil
+		}
+		select {
+		case <-ch:
+			q.notFull.done()
+		case <-ctx.Done():
+			q.notFull.done()
+			return ctx.Err()
+		}
+	}
+}
+
+// Pop removes an item from the head of the queue, waiting for one if the queue is empty.
+// It returns ctx.Err() if ctx is done before an item is popped.
+func (q *Queue[T]) Pop(ctx context.Context) (T, error) {
+	for {
+		if v, ok := q.TryPop(); ok {
+			return v, nil
+		}
+
+		ch := q.notEmpty.wait()
+		if v, ok := q.TryPop(); ok {
+			q.notEmpty.done()
+			return v, nil
+		}
+		select {
+		case <-ch:
+			q.notEmpty.done()
+		case <-ctx.Done():
+			q.notEmpty.done()
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// PushBatch adds the items to the tail of the queue until it's full, and returns the number of items pushed.
+// The items are pushed one by one, so they may interleave with the items of other producers.
+func (q *Queue[T]) PushBatch(items []T) int {
+	var n int
+	for n < len(items) && q.tryPush(items[n]) {
+		n++
+	}
+	if n > 0 {
+		q.notEmpty.broadcast()
+	}
+
+	return n
+}
+
+// PopBatch removes up to len(buf) items from the head of the queue into buf, and returns the number of items popped.
+func (q *Queue[T]) PopBatch(buf []T) int {
+	var n int
+	for n < len(buf) {
+		v, ok := q.tryPop()
+		if !ok {
+			break
+		}
+		buf[n] = v
+		n++
+	}
+	if n > 0 {
+		q.notFull.broadcast()
+	}
+
+	return n
+}
+
+// Len returns the number of items in the queue, it's not exact under concurrent pushes and pops.
+func (q *Queue[T]) Len() int {
+	head := q.head.Load()
+	tail := q.tail.Load()
+	if tail <= head {
+		return 0
+	}
+	if n := tail - head; n < uint64(len(q.slots)) {
+		return int(n)
+	}
+
+	return len(q.slots)
+}
+
+// Cap returns the capacity of the queue.
+func (q *Queue[T]) Cap() int {
+	return len(q.slots)
+}
+
+// wait registers a waiter, and returns the channel closed on the next broadcast.
+// The waiter must call done once it stops waiting.
+func (w *waiters) wait() <-chan struct{} {
+	w.n.Add(1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ch == nil {
+		w.ch = make(chan struct{})
+	}
+	return w.ch
+}
+
+func (w *waiters) done() {
+	w.n.Add(-1)
+}
+
+// broadcast wakes up all the waiters, it's cheap when nobody waits.
+func (w *waiters) broadcast() {
+	if w.n.Load() == 0 {
+		return
+	}
+	w.mu.Lock()
+	if w.ch != nil {
+		close(w.ch)
+		w.ch = nil
+	}
+	w.mu.Unlock()
+}
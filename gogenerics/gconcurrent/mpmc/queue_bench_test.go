@@ -0,0 +1,112 @@
+package mpmc
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+const benchCapacity = 1024
+
+// BenchmarkTryPushPop pushes and pops in every goroutine without blocking.
+func BenchmarkTryPushPop(b *testing.B) {
+	b.Run("Queue", func(b *testing.B) {
+		q := New[int](benchCapacity)
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				q.TryPush(i)
+				q.TryPop()
+			}
+		})
+	})
+	b.Run("chan", func(b *testing.B) {
+		ch := make(chan int, benchCapacity)
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				select {
+				case ch <- i:
+				default:
+				}
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkProducerConsumer moves b.N items from GOMAXPROCS producers to GOMAXPROCS consumers.
+func BenchmarkProducerConsumer(b *testing.B) {
+	procs := runtime.GOMAXPROCS(0)
+	run := func(b *testing.B, push func(int), pop func()) {
+		var wg sync.WaitGroup
+		for p := 0; p < procs; p++ {
+			n := b.N / procs
+			if p == 0 {
+				n += b.N % procs
+			}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					push(i)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					pop()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	b.Run("Queue", func(b *testing.B) {
+		q := New[int](benchCapacity)
+		ctx := context.Background()
+		run(b, func(v int) {
+			_ = q.Push(ctx, v)
+		}, func() {
+			_, _ = q.Pop(ctx)
+		})
+	})
+	b.Run("chan", func(b *testing.B) {
+		ch := make(chan int, benchCapacity)
+		run(b, func(v int) {
+			ch <- v
+		}, func() {
+			<-ch
+		})
+	})
+}
+
+// BenchmarkBatch moves the items in batches of 64.
+func BenchmarkBatch(b *testing.B) {
+	const batch = 64
+
+	b.Run("Queue", func(b *testing.B) {
+		q := New[int](benchCapacity)
+		items := make([]int, batch)
+		buf := make([]int, batch)
+		for i := 0; i < b.N; i += batch {
+			q.PushBatch(items)
+			q.PopBatch(buf)
+		}
+	})
+	b.Run("chan", func(b *testing.B) {
+		ch := make(chan int, benchCapacity)
+		items := make([]int, batch)
+		buf := make([]int, batch)
+		for i := 0; i < b.N; i += batch {
+			for _, v := range items {
+				ch <- v
+			}
+			for j := range buf {
+				buf[j] = <-ch
+			}
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package mpmc
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	q := New[int](3)
+	assert.Equal(4, q.Cap())
+	_, ok := q.TryPop()
+	assert.False(ok)
+
+	for i := 0; i < 4; i++ {
+		assert.True(q.TryPush(i))
+	}
+	assert.False(q.TryPush(4))
+	assert.Equal(4, q.Len())
+
+	// wraps around the ring
+	for lap := 0; lap < 3; lap++ {
+		for i := 0; i < 4; i++ {
+			v, ok := q.TryPop()
+			assert.True(ok)
+			assert.Equal(lap*4+i, v)
+			assert.True(q.TryPush((lap+1)*4 + i))
+		}
+	}
+	assert.Equal(4, q.Len())
+}
+
+func TestQueueBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	q := New[int](8)
+	assert.Equal(5, q.PushBatch([]int{0, 1, 2, 3, 4}))
+	assert.Equal(3, q.PushBatch([]int{5, 6, 7, 8, 9}))
+	assert.Equal(0, q.PushBatch([]int{10}))
+
+	buf := make([]int, 6)
+	assert.Equal(6, q.PopBatch(buf))
+	assert.Equal([]int{0, 1, 2, 3, 4, 5}, buf)
+	assert.Equal(2, q.PopBatch(buf))
+	assert.Equal([]int{6, 7}, buf[:2])
+	assert.Equal(0, q.PopBatch(buf))
+	assert.Equal(0, q.Len())
+}
+
+func TestQueueBlocking(t *testing.T) {
+	assert := assert.New(t)
+
+	q := New[int](2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.Pop(ctx)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+
+	assert.Nil(q.Push(context.Background(), 1))
+	assert.Nil(q.Push(context.Background(), 2))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(q.Push(ctx, 3), context.DeadlineExceeded)
+
+	// a blocked Push is woken up by Pop
+	pushed := make(chan error)
+	go func() {
+		pushed <- q.Push(context.Background(), 3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	v, err := q.Pop(context.Background())
+	assert.Nil(err)
+	assert.Equal(1, v)
+	assert.Nil(<-pushed)
+
+	// a blocked Pop is woken up by PushBatch
+	q.PopBatch(make([]int, 2))
+	popped := make(chan int)
+	go func() {
+		v, _ := q.Pop(context.Background())
+		popped <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.PushBatch([]int{4})
+	assert.Equal(4, <-popped)
+}
+
+func TestQueueConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	const perProducer = 10000
+	procs := runtime.GOMAXPROCS(0)
+	if procs < 4 {
+		procs = 4
+	}
+	q := New[int](16)
+	ctx := context.Background()
+
+	var producers sync.WaitGroup
+	for p := 0; p < procs; p++ {
+		producers.Add(1)
+		go func(p int) {
+			defer producers.Done()
+			for i := 0; i < perProducer; i++ {
+				if err := q.Push(ctx, p*perProducer+i); err != nil {
+					t.Error(err)
+				}
+			}
+		}(p)
+	}
+
+	results := make(chan []int, procs)
+	for c := 0; c < procs; c++ {
+		go func() {
+			var got []int
+			buf := make([]int, 4)
+			for len(got) < perProducer {
+				size := len(buf)
+				if rest := perProducer - len(got); rest < size {
+					size = rest
+				}
+				if n := q.PopBatch(buf[:size]); n > 0 {
+					got = append(got, buf[:n]...)
+					continue
+				}
+				v, err := q.Pop(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				got = append(got, v)
+			}
+			results <- got
+		}()
+	}
+	producers.Wait()
+
+	seen := make([]bool, procs*perProducer)
+	last := make([]int, procs)
+	for c := 0; c < procs; c++ {
+		got := <-results
+		for i := range last {
+			last[i] = -1
+		}
+		for _, v := range got {
+			assert.False(seen[v])
+			seen[v] = true
+			// FIFO per producer, as seen by every consumer
+			p := v / perProducer
+			assert.Less(last[p], v)
+			last[p] = v
+		}
+	}
+	for _, ok := range seen {
+		assert.True(ok)
+	}
+}
@@ -0,0 +1,205 @@
+package mr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyTolerance is how many times the baseline latency a sample can take before it's congestion.
+	latencyTolerance = 2
+	// backoffRatio is the multiplicative decrease of the limit on congestion or errors.
+	backoffRatio = 0.9
+	// baselineSmoothing is the weight of a normal sample in the baseline latency.
+	baselineSmoothing = 0.1
+	// driftSmoothing is the weight of a congested sample in the baseline latency,
+	// so that the baseline follows a permanent change slowly.
+	driftSmoothing = 0.01
+)
+
+type (
+	adaptiveOptions struct {
+		min int
+		max int
+	}
+
+	// adaptiveLimit limits the number of items being mapped with AIMD: the limit grows by 1
+	// every limit items that succeeded in time while all the workers were busy, and shrinks
+	// by backoffRatio when an item failed or took latencyTolerance times the baseline latency.
+	adaptiveLimit struct {
+		mu           sync.Mutex
+		min          int
+		max          int
+		limit        float64
+		inFlight     int
+		baseline     float64
+		lastDecrease time.Time
+		changed      chan struct{}
+		onChange     func(limit int)
+	}
+)
+
+// WithAdaptiveWorkers customizes a mapreduce processing to adjust the number of workers
+// between min and max, it starts with min workers and grows while the item latency stays flat,
+// and shrinks when the latency goes up or items fail, an item fails if it called cancel or panicked.
+// It replaces WithWorkers, the current limit is reported to Hooks.OnLimitChange.
+func WithAdaptiveWorkers(min, max int) Option {
+	return func(opts *mapReduceOptions) {
+		if min < minWorkers {
+			min = minWorkers
+		}
+		if max < min {
+			max = min
+		}
+		opts.adaptive = &adaptiveOptions{min: min, max: max}
+		opts.workers = max
+	}
+}
+
+// newAdaptiveLimit returns nil if WithAdaptiveWorkers is not used.
+func newAdaptiveLimit(options *mapReduceOptions) *adaptiveLimit {
+	if options.adaptive == nil {
+		return nil
+	}
+
+	al := &adaptiveLimit{
+		min:     options.adaptive.min,
+		max:     options.adaptive.max,
+		changed: make(chan struct{}),
+	}
+	// WithWorkers after WithAdaptiveWorkers caps the limit
+	if al.max > options.workers {
+		al.max = options.workers
+	}
+	if al.min > al.max {
+		al.min = al.max
+	}
+	al.limit = float64(al.min)
+	if options.hooks != nil && options.hooks.OnLimitChange != nil {
+		al.onChange = options.hooks.OnLimitChange
+		al.onChange(al.min)
+	}
+
+	return al
+}
+
+// acquire waits for the number of items being mapped to go below the limit,
+// it returns false if ctx or done is done first.
+func (al *adaptiveLimit) acquire(ctx context.Context, done <-chan struct{}) bool {
+	if al == nil {
+		return true
+	}
+
+	for {
+		al.mu.Lock()
+		if al.inFlight < int(al.limit) {
+			al.inFlight++
+			al.mu.Unlock()
+			return true
+		}
+		changed := al.changed
+		al.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		case <-changed:
+		}
+	}
+}
+
+func (al *adaptiveLimit) release() {
+	if al == nil {
+		return
+	}
+
+	al.mu.Lock()
+	al.inFlight--
+	al.notify()
+	al.mu.Unlock()
+}
+
+// observe adjusts the limit with the latency and the result of an item.
+func (al *adaptiveLimit) observe(latency time.Duration, failed bool) {
+	al.mu.Lock()
+	prev := int(al.limit)
+	sample := float64(latency)
+	switch {
+	case al.baseline == 0 && !failed:
+		al.baseline = sample
+	case failed:
+		al.decrease()
+	case sample > al.baseline*latencyTolerance:
+		al.baseline += (sample - al.baseline) * driftSmoothing
+		al.decrease()
+	default:
+		al.baseline += (sample - al.baseline) * baselineSmoothing
+		// only grow when the limit is reached, otherwise the limit is not what holds the throughput
+		if al.inFlight >= prev && al.limit < float64(al.max) {
+			al.limit += 1 / al.limit
+			if al.limit > float64(al.max) {
+				al.limit = float64(al.max)
+			}
+		}
+	}
+
+	limit := int(al.limit)
+	if limit != prev {
+		al.notify()
+	}
+	al.mu.Unlock()
+
+	if limit != prev && al.onChange != nil {
+		al.onChange(limit)
+	}
+}
+
+// decrease shrinks the limit at most once per baseline latency, so that the items
+// of the same congestion don't shrink it over and over. It must be called with mu held.
+func (al *adaptiveLimit) decrease() {
+	now := time.Now()
+	if now.Sub(al.lastDecrease) < time.Duration(al.baseline) {
+		return
+	}
+
+	al.lastDecrease = now
+	al.limit *= backoffRatio
+	if al.limit < float64(al.min) {
+		al.limit = float64(al.min)
+	}
+}
+
+// notify wakes up the waiting acquire calls, it must be called with mu held.
+func (al *adaptiveLimit) notify() {
+	close(al.changed)
+	al.changed = make(chan struct{})
+}
+
+// adaptiveMapper wraps mapper to report the latency and the result of every item to al,
+// it returns mapper itself if al is nil.
+func adaptiveMapper[T, U any](mapper MapperCtxFunc[T, U], al *adaptiveLimit) MapperCtxFunc[T, U] {
+	if al == nil {
+		return mapper
+	}
+
+	return func(ctx context.Context, item T, writer Writer[U], cancel func(error)) {
+		var at attempt
+		start := time.Now()
+		defer func() {
+			r := recover()
+			called, _ := at.result()
+			al.observe(time.Since(start), called || r != nil)
+			if r != nil {
+				panic(r)
+			}
+		}()
+
+		mapper(ctx, item, writer, func(err error) {
+			at.cancel(err)
+			cancel(err)
+		})
+	}
+}
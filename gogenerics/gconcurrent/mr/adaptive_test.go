@@ -0,0 +1,143 @@
+package mr
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithAdaptiveWorkers(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var (
+		mu     sync.Mutex
+		limits []int
+		// raised is closed once the limit is above 1, then the first two items mapped
+		// wait for each other at the barrier, which is passed once both are running
+		raised, passed         = make(chan struct{}), make(chan struct{})
+		raiseOnce, passOnce    sync.Once
+		running, peak, waiting int32
+	)
+	ForEach(func(source chan<- int) {
+		// keep generating until the barrier is passed, so that it's never short of items
+		for i := 0; ; i++ {
+			if i >= 200 {
+				select {
+				case <-passed:
+					return
+				default:
+				}
+			}
+			source <- i
+		}
+	}, func(item int) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		select {
+		case <-raised:
+			if atomic.AddInt32(&waiting, 1) == 2 {
+				passOnce.Do(func() { close(passed) })
+			}
+			<-passed
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}, WithAdaptiveWorkers(1, 4), WithHooks(Hooks{
+		OnLimitChange: func(limit int) {
+			mu.Lock()
+			limits = append(limits, limit)
+			mu.Unlock()
+			if limit > 1 {
+				raiseOnce.Do(func() { close(raised) })
+			}
+		},
+	}))
+
+	// the limit starts from min, and grows within the bounds with the flat latency,
+	// the sleep jitter may take it down on the way
+	assert.Equal(1, limits[0])
+	highest := limits[0]
+	for _, limit := range limits {
+		assert.GreaterOrEqual(limit, 1)
+		assert.LessOrEqual(limit, 4)
+		if limit > highest {
+			highest = limit
+		}
+	}
+	assert.Greater(highest, 1)
+	// more than one item is mapped at a time once the limit is raised
+	assert.Greater(atomic.LoadInt32(&peak), int32(1))
+	assert.LessOrEqual(atomic.LoadInt32(&peak), int32(4))
+}
+
+func TestAdaptiveLimitObserve(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	al := newAdaptiveLimit(buildOptions(WithAdaptiveWorkers(2, 10)))
+	assert.Equal(float64(2), al.limit)
+
+	// not growing while the limit is not reached
+	al.observe(time.Millisecond, false)
+	al.observe(time.Millisecond, false)
+	assert.Equal(float64(2), al.limit)
+
+	// all the workers are busy
+	al.inFlight = 10
+	for i := 0; i < 100; i++ {
+		al.observe(time.Millisecond, false)
+	}
+	assert.Equal(float64(10), al.limit)
+
+	// the latency goes up
+	al.observe(10*time.Millisecond, false)
+	assert.Equal(float64(9), al.limit)
+	// shrinks once per baseline latency
+	al.observe(10*time.Millisecond, false)
+	assert.Equal(float64(9), al.limit)
+	time.Sleep(2 * time.Millisecond)
+	al.observe(time.Millisecond, true)
+	assert.InDelta(8.1, al.limit, 1e-9)
+
+	for i := 0; i < 100; i++ {
+		al.lastDecrease = time.Time{}
+		al.observe(time.Millisecond, true)
+	}
+	assert.Equal(float64(2), al.limit)
+}
+
+func TestAdaptiveLimitAcquire(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	al := newAdaptiveLimit(buildOptions(WithAdaptiveWorkers(1, 2)))
+	assert.True(al.acquire(context.Background(), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.False(al.acquire(ctx, nil))
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- al.acquire(context.Background(), nil)
+	}()
+	al.release()
+	assert.True(<-acquired)
+
+	assert.Nil(newAdaptiveLimit(buildOptions()))
+	// a nil limit never blocks
+	var nilLimit *adaptiveLimit
+	assert.True(nilLimit.acquire(ctx, nil))
+}
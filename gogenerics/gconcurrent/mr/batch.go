@@ -0,0 +1,111 @@
+package mr
+
+import (
+	"context"
+	"time"
+)
+
+// Batch groups the elements from source into batches of at most size elements,
+// a batch is also sent once wait passed since its first element, if wait > 0.
+// The last partial batch is sent when source is closed.
+//
+// Batch doesn't read from source while a full batch is not taken by the consumer,
+// so a slow consumer slows down the producer. Once ctx is done, it drains source
+// and closes the returned channel.
+func Batch[T any](ctx context.Context, source <-chan T, size int, wait time.Duration) <-chan []T {
+	if size < 1 {
+		size = 1
+	}
+
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		defer drain(source)
+
+		var (
+			batch   []T
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- batch:
+				batch = nil
+				return true
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case item, ok := <-source:
+				if !ok {
+					flush()
+					return
+				}
+
+				if batch == nil {
+					batch = make([]T, 0, size)
+					if wait > 0 {
+						timer = time.NewTimer(wait)
+						timeout = timer.C
+					}
+				}
+				batch = append(batch, item)
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-timeout:
+				if !flush() {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// MapReduceBatch maps the elements generated from given generate func in batches of at most size elements,
+// or the elements arrived in wait, and reduces the output elements with given reducer.
+func MapReduceBatch[T, U, V any](generate GenerateFunc[T], size int, wait time.Duration,
+	mapper MapperFunc[[]T, U], reducer ReducerFunc[U, V], opts ...Option) (V, error) {
+	return MapReduceBatchCtx(func(_ context.Context, source chan<- T) {
+		generate(source)
+	}, size, wait, withoutCtxMapper(mapper), withoutCtxReducer(reducer), opts...)
+}
+
+// MapReduceBatchCtx is like MapReduceBatch, but the callbacks receive the pipeline context.
+func MapReduceBatchCtx[T, U, V any](generate GenerateCtxFunc[T], size int, wait time.Duration,
+	mapper MapperCtxFunc[[]T, U], reducer ReducerCtxFunc[U, V], opts ...Option) (V, error) {
+	options := buildOptions(opts...)
+	ctx, cancel := context.WithCancelCause(options.ctx)
+	defer cancel(nil)
+
+	panicChan := &onceChan{channel: make(chan any)}
+	source := Batch(ctx, buildSource(ctx, generate, panicChan), size, wait)
+	return mapReduceWithPanicChan(ctx, cancel, source, panicChan, mapper, reducer, options)
+}
+
+// AddBatchStage returns a Pipeline that groups the output of p into batches, the same as Batch.
+func AddBatchStage[T any](p *Pipeline[T], size int, wait time.Duration) *Pipeline[[]T] {
+	return &Pipeline[[]T]{
+		start: func(ps *pipelineState) <-chan []T {
+			return Batch(ps.ctx, p.start(ps), size, wait)
+		},
+	}
+}
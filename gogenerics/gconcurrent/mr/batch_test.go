@@ -0,0 +1,113 @@
+package mr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	source := make(chan int)
+	go func() {
+		defer close(source)
+		for i := 0; i < 7; i++ {
+			source <- i
+		}
+	}()
+
+	var batches [][]int
+	for batch := range Batch(context.Background(), source, 3, 0) {
+		batches = append(batches, batch)
+	}
+	assert.Equal([][]int{{0, 1, 2}, {3, 4, 5}, {6}}, batches)
+}
+
+func TestBatchWait(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	source := make(chan int)
+	release := make(chan struct{})
+	go func() {
+		defer close(source)
+		source <- 1
+		source <- 2
+		<-release
+		source <- 3
+	}()
+
+	batches := Batch(context.Background(), source, 100, 20*time.Millisecond)
+	// the first batch is sent on time, not when it's full
+	assert.Equal([]int{1, 2}, <-batches)
+	close(release)
+	assert.Equal([]int{3}, <-batches)
+	_, ok := <-batches
+	assert.False(ok)
+}
+
+func TestBatchCancelled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan int)
+	go func() {
+		defer close(source)
+		for i := 0; i < 100; i++ {
+			source <- i
+		}
+	}()
+
+	batches := Batch(ctx, source, 10, 0)
+	assert.Len(<-batches, 10)
+	cancel()
+	for range batches {
+	}
+}
+
+func TestMapReduceBatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	val, err := MapReduceBatch(func(source chan<- int) {
+		for i := 1; i <= 250; i++ {
+			source <- i
+		}
+	}, 100, time.Second, func(batch []int, writer Writer[int], cancel func(error)) {
+		if len(batch) > 100 {
+			cancel(errDummy)
+			return
+		}
+		var sum int
+		for _, v := range batch {
+			sum += v
+		}
+		writer.Write(sum)
+	}, func(pipe <-chan int, writer Writer[int], cancel func(error)) {
+		var sum int
+		for v := range pipe {
+			sum += v
+		}
+		writer.Write(sum)
+	}, WithWorkers(2))
+	assert.Nil(err)
+	assert.Equal(250*251/2, val)
+}
+
+func TestAddBatchStage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := AddStage(AddBatchStage(PipelineFromSlice([]int{1, 2, 3, 4, 5}), 2, 0),
+		func(ctx context.Context, batch []int, writer Writer[int], cancel func(error)) {
+			writer.Write(len(batch))
+		})
+	sizes, err := p.Collect(context.Background())
+	assert.Nil(err)
+	assert.ElementsMatch([]int{2, 2, 1}, sizes)
+}
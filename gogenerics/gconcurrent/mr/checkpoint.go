@@ -0,0 +1,327 @@
+package mr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultCheckpointInterval = 10 * time.Second
+
+var _ CheckpointStore = (*FileCheckpointStore)(nil)
+
+type (
+	// Checkpoint is the progress of a resumable processing, items are identified by
+	// their offsets in the order they're generated.
+	Checkpoint struct {
+		// Offset is the number of leading items that are all completed, or failed with WithCollectErrors.
+		Offset int64 `json:"offset"`
+		// Done is the sorted offsets of the completed items after Offset.
+		Done []int64 `json:"done,omitempty"`
+		// Failed is the sorted offsets of the failed items before Offset, they run again on restart.
+		Failed []int64 `json:"failed,omitempty"`
+	}
+
+	// CheckpointStore persists the Checkpoint of a resumable processing.
+	CheckpointStore interface {
+		// Load returns the saved Checkpoint, or an empty one if nothing is saved.
+		Load(ctx context.Context) (Checkpoint, error)
+		// Save replaces the saved Checkpoint with cp.
+		Save(ctx context.Context, cp Checkpoint) error
+	}
+
+	// FileCheckpointStore is a CheckpointStore that saves the Checkpoint as json in a file.
+	// The file is replaced atomically, remove it to start over.
+	FileCheckpointStore struct {
+		// Path is the path of the checkpoint file, required.
+		Path string
+	}
+
+	checkpointTracker struct {
+		mu     sync.Mutex
+		offset int64
+		done   map[int64]struct{}
+		// failed is the failed offsets, the ones before offset are to run again
+		failed map[int64]struct{}
+		dirty  bool
+	}
+
+	// valueOnlyContext is context.WithoutCancel, which is not available in go1.20.
+	valueOnlyContext struct {
+		context.Context
+	}
+)
+
+// WithCheckpointInterval customizes how often ForEachResumable saves the checkpoint, it defaults to 10s.
+func WithCheckpointInterval(interval time.Duration) Option {
+	return func(opts *mapReduceOptions) {
+		opts.checkpointInterval = interval
+	}
+}
+
+// ForEachResumable runs fn on every element generated from given generate func, like ForEach,
+// and saves the offsets of the completed elements to store periodically and once more at the end.
+// Elements that are completed in the saved checkpoint are skipped, so a crashed processing
+// can be restarted where it stopped, generate must generate the elements in the same order every time.
+//
+// An element is completed once fn returned nil, elements in progress at a crash run again
+// on restart, so fn must be idempotent. It stops on the first error and returns it,
+// with WithCollectErrors it runs all elements, the failed ones run again on restart,
+// and they don't hold the checkpoint back, see Checkpoint.Failed.
+func ForEachResumable[T any](ctx context.Context, store CheckpointStore, generate GenerateCtxFunc[T],
+	fn func(ctx context.Context, item T) error, opts ...Option) (err error) {
+	cp, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	options := buildOptions(opts...)
+	tracker := newCheckpointTracker(cp)
+	stop := tracker.persist(ctx, store, options.checkpointInterval)
+	defer func() {
+		stop()
+		// save the progress even if ctx is cancelled
+		if saveErr := store.Save(valueOnlyContext{ctx}, tracker.checkpoint()); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+	}()
+
+	// the explicit ctx always wins over WithContext in opts
+	opts = append(opts[:len(opts):len(opts)], WithContext(ctx))
+	return MapReduceVoidCtx(func(ctx context.Context, source chan<- indexedItem[T]) {
+		items := make(chan T)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// keep draining, generate might not check ctx
+			defer drain(items)
+
+			var offset int64
+			for item := range items {
+				if !tracker.completed(offset) {
+					select {
+					case <-ctx.Done():
+						return
+					case source <- indexedItem[T]{index: int(offset), item: item}:
+					}
+				}
+				offset++
+			}
+		}()
+		defer func() {
+			close(items)
+			<-done
+		}()
+
+		generate(ctx, items)
+	}, func(ctx context.Context, item indexedItem[T], writer Writer[any], cancel func(error)) {
+		if err := fn(ctx, item.item); err != nil {
+			if options.collectErrors {
+				tracker.fail(int64(item.index))
+			}
+			cancel(err)
+			return
+		}
+
+		tracker.complete(int64(item.index))
+	}, func(_ context.Context, pipe <-chan any, cancel func(error)) {
+		drain(pipe)
+	}, opts...)
+}
+
+// Load implements CheckpointStore.
+func (fs *FileCheckpointStore) Load(_ context.Context) (Checkpoint, error) {
+	var cp Checkpoint
+	content, err := os.ReadFile(fs.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		return cp, err
+	}
+
+	err = json.Unmarshal(content, &cp)
+	return cp, err
+}
+
+// Save implements CheckpointStore.
+func (fs *FileCheckpointStore) Save(_ context.Context, cp Checkpoint) error {
+	content, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(fs.Path)
+	if err = os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(fs.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fs.Path)
+}
+
+func newCheckpointTracker(cp Checkpoint) *checkpointTracker {
+	ct := &checkpointTracker{
+		offset: cp.Offset,
+		done:   make(map[int64]struct{}, len(cp.Done)),
+		failed: make(map[int64]struct{}, len(cp.Failed)),
+	}
+	for _, offset := range cp.Done {
+		if offset >= ct.offset {
+			ct.done[offset] = struct{}{}
+		}
+	}
+	for _, offset := range cp.Failed {
+		if offset < ct.offset {
+			ct.failed[offset] = struct{}{}
+		}
+	}
+	ct.advance()
+
+	return ct
+}
+
+func (ct *checkpointTracker) completed(offset int64) bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if offset < ct.offset {
+		_, failed := ct.failed[offset]
+		return !failed
+	}
+	_, ok := ct.done[offset]
+	return ok
+}
+
+func (ct *checkpointTracker) complete(offset int64) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	delete(ct.failed, offset)
+	if offset >= ct.offset {
+		ct.done[offset] = struct{}{}
+		ct.advance()
+	}
+	ct.dirty = true
+}
+
+func (ct *checkpointTracker) fail(offset int64) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.failed[offset] = struct{}{}
+	ct.advance()
+	ct.dirty = true
+}
+
+// advance moves offset over the completed and failed offsets, it must be called with mu held.
+func (ct *checkpointTracker) advance() {
+	for {
+		if _, ok := ct.done[ct.offset]; ok {
+			delete(ct.done, ct.offset)
+		} else if _, ok = ct.failed[ct.offset]; !ok {
+			return
+		}
+		ct.offset++
+	}
+}
+
+func (ct *checkpointTracker) checkpoint() Checkpoint {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.dirty = false
+	cp := Checkpoint{Offset: ct.offset}
+	for offset := range ct.done {
+		cp.Done = append(cp.Done, offset)
+	}
+	// the failed offsets after offset are not saved, they run again anyway
+	for offset := range ct.failed {
+		if offset < ct.offset {
+			cp.Failed = append(cp.Failed, offset)
+		}
+	}
+	sortOffsets(cp.Done)
+	sortOffsets(cp.Failed)
+
+	return cp
+}
+
+func sortOffsets(offsets []int64) {
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] < offsets[j]
+	})
+}
+
+// persist saves the checkpoint to store every interval if it changed, until the returned stop func is called.
+// A failed save is retried at the next interval, the final save reports the error.
+func (ct *checkpointTracker) persist(ctx context.Context, store CheckpointStore, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				ct.mu.Lock()
+				dirty := ct.dirty
+				ct.mu.Unlock()
+				if !dirty {
+					continue
+				}
+				if err := store.Save(ctx, ct.checkpoint()); err != nil {
+					ct.mu.Lock()
+					ct.dirty = true
+					ct.mu.Unlock()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+func (valueOnlyContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (valueOnlyContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (valueOnlyContext) Err() error {
+	return nil
+}
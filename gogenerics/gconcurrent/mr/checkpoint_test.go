@@ -0,0 +1,184 @@
+package mr
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generateN(n int) GenerateCtxFunc[int] {
+	return func(ctx context.Context, source chan<- int) {
+		for i := 0; i < n; i++ {
+			source <- i
+		}
+	}
+}
+
+func TestForEachResumable(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	store := &FileCheckpointStore{Path: filepath.Join(t.TempDir(), "backfill", "checkpoint.json")}
+	var (
+		mu   sync.Mutex
+		runs = make(map[int]int)
+	)
+	record := func(item int) {
+		mu.Lock()
+		runs[item]++
+		mu.Unlock()
+	}
+
+	// the first run fails at item 60
+	err := ForEachResumable(context.Background(), store, generateN(100), func(ctx context.Context, item int) error {
+		if item == 60 {
+			return errDummy
+		}
+		record(item)
+		return nil
+	}, WithWorkers(4))
+	assert.ErrorIs(err, errDummy)
+
+	cp, err := store.Load(context.Background())
+	assert.Nil(err)
+	assert.True(cp.Offset <= 60)
+	assert.NotContains(cp.Done, int64(60))
+	saved := newCheckpointTracker(cp)
+
+	// the second run completes the rest, and skips the saved items
+	var rerun []int
+	assert.Nil(ForEachResumable(context.Background(), store, generateN(100), func(ctx context.Context, item int) error {
+		if saved.completed(int64(item)) {
+			mu.Lock()
+			rerun = append(rerun, item)
+			mu.Unlock()
+		}
+		record(item)
+		return nil
+	}, WithWorkers(4)))
+	assert.Empty(rerun, "completed items run again")
+	assert.Len(runs, 100)
+
+	cp, err = store.Load(context.Background())
+	assert.Nil(err)
+	assert.Equal(Checkpoint{Offset: 100}, cp)
+}
+
+func TestForEachResumableCollectErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	store := &FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	// the failed items don't hold the offset back
+	err := ForEachResumable(context.Background(), store, generateN(100), func(ctx context.Context, item int) error {
+		if item%10 == 3 {
+			return errDummy
+		}
+		return nil
+	}, WithWorkers(4), WithCollectErrors())
+	var ae *AggregateError
+	assert.ErrorAs(err, &ae)
+	assert.Len(ae.Errors, 10)
+
+	cp, err := store.Load(context.Background())
+	assert.Nil(err)
+	assert.Equal(Checkpoint{Offset: 100, Failed: []int64{3, 13, 23, 33, 43, 53, 63, 73, 83, 93}}, cp)
+
+	// only the failed items run again
+	var (
+		mu    sync.Mutex
+		rerun []int
+	)
+	err = ForEachResumable(context.Background(), store, generateN(100), func(ctx context.Context, item int) error {
+		mu.Lock()
+		rerun = append(rerun, item)
+		mu.Unlock()
+		if item == 43 {
+			return errDummy
+		}
+		return nil
+	}, WithWorkers(4), WithCollectErrors())
+	assert.ErrorIs(err, errDummy)
+	assert.ElementsMatch([]int{3, 13, 23, 33, 43, 53, 63, 73, 83, 93}, rerun)
+
+	cp, err = store.Load(context.Background())
+	assert.Nil(err)
+	assert.Equal(Checkpoint{Offset: 100, Failed: []int64{43}}, cp)
+}
+
+func TestForEachResumableInterval(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	store := &FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- ForEachResumable(ctx, store, generateN(10), func(ctx context.Context, item int) error {
+			if item == 5 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-release:
+				}
+			}
+			return nil
+		}, WithWorkers(1), WithCheckpointInterval(time.Millisecond))
+	}()
+
+	// items before 5 are saved while 5 is still running
+	assert.Eventually(func() bool {
+		cp, err := store.Load(context.Background())
+		return err == nil && cp.Offset == 5
+	}, time.Second, time.Millisecond)
+
+	// the progress is saved on cancellation
+	cancel()
+	assert.Error(<-done)
+	cp, err := store.Load(context.Background())
+	assert.Nil(err)
+	assert.Equal(int64(5), cp.Offset)
+	close(release)
+}
+
+func TestCheckpointTracker(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ct := newCheckpointTracker(Checkpoint{Offset: 2, Done: []int64{1, 3, 5}})
+	assert.Equal(Checkpoint{Offset: 2, Done: []int64{3, 5}}, ct.checkpoint())
+	assert.True(ct.completed(0))
+	assert.False(ct.completed(2))
+	assert.True(ct.completed(3))
+	assert.False(ct.completed(4))
+
+	ct.complete(7)
+	ct.complete(2)
+	assert.Equal(Checkpoint{Offset: 4, Done: []int64{5, 7}}, ct.checkpoint())
+	ct.complete(4)
+	assert.Equal(Checkpoint{Offset: 6, Done: []int64{7}}, ct.checkpoint())
+
+	// a failed offset is kept aside, and runs again until it's completed
+	ct.fail(6)
+	assert.Equal(Checkpoint{Offset: 8, Failed: []int64{6}}, ct.checkpoint())
+	ct.fail(9)
+	assert.Equal(Checkpoint{Offset: 8, Failed: []int64{6}}, ct.checkpoint())
+	ct.complete(8)
+	assert.Equal(Checkpoint{Offset: 10, Failed: []int64{6, 9}}, ct.checkpoint())
+	assert.False(ct.completed(6))
+	assert.True(ct.completed(7))
+
+	ct = newCheckpointTracker(ct.checkpoint())
+	assert.False(ct.completed(9))
+	ct.complete(9)
+	ct.complete(6)
+	assert.Equal(Checkpoint{Offset: 10}, ct.checkpoint())
+	assert.True(ct.completed(6))
+}
@@ -0,0 +1,358 @@
+package dmr
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miniLCT/gosb/gogenerics/gcontainers/gqueue"
+)
+
+const (
+	defaultHeartbeat = time.Second
+	// missedHeartbeats is the number of heartbeat intervals after which a silent worker is dead.
+	missedHeartbeats = 3
+)
+
+type (
+	// Coordinator hands out the items of ForEach and MapReduce to the workers connected to it.
+	// A worker that misses heartbeats is considered dead, and its tasks are reassigned.
+	Coordinator struct {
+		listener  net.Listener
+		server    *rpc.Server
+		heartbeat time.Duration
+		quit      chan struct{}
+		closeOnce sync.Once
+		wg        sync.WaitGroup
+
+		mu         sync.Mutex
+		conns      map[net.Conn]struct{}
+		nextTask   uint64
+		nextWorker uint64
+		workers    map[string]*workerState
+		queues     map[string]*gqueue.Queue[*task]
+		// wakeup is closed and replaced when a task is queued
+		wakeup chan struct{}
+	}
+
+	workerState struct {
+		mappers  []string
+		lastSeen time.Time
+		tasks    map[uint64]*task
+	}
+
+	task struct {
+		id     uint64
+		mapper string
+		item   []byte
+		// worker is the assignee, empty while queued
+		worker    string
+		cancelled bool
+		done      chan struct{}
+		outputs   [][]byte
+		err       error
+	}
+
+	// rpcService exposes a Coordinator to the workers.
+	rpcService struct {
+		c *Coordinator
+	}
+)
+
+// NewCoordinator returns a Coordinator listening on addr, use ":0" or "127.0.0.1:0"
+// for a random port, see Addr. Workers send a heartbeat every heartbeat, it defaults to 1s.
+func NewCoordinator(addr string, heartbeat time.Duration) (*Coordinator, error) {
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Coordinator{
+		listener:  listener,
+		server:    rpc.NewServer(),
+		heartbeat: heartbeat,
+		quit:      make(chan struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		workers:   make(map[string]*workerState),
+		queues:    make(map[string]*gqueue.Queue[*task]),
+		wakeup:    make(chan struct{}),
+	}
+	if err = c.server.RegisterName(serviceName, &rpcService{c: c}); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	c.wg.Add(2)
+	go c.serve()
+	go c.reap()
+
+	return c, nil
+}
+
+// Addr returns the address the Coordinator listens on.
+func (c *Coordinator) Addr() string {
+	return c.listener.Addr().String()
+}
+
+// Close stops the Coordinator, the running ForEach and MapReduce fail with ErrClosed.
+func (c *Coordinator) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		err = c.listener.Close()
+		c.mu.Lock()
+		for conn := range c.conns {
+			_ = conn.Close()
+		}
+		c.mu.Unlock()
+		c.wg.Wait()
+	})
+
+	return err
+}
+
+// Workers returns the number of live workers.
+func (c *Coordinator) Workers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.workers)
+}
+
+// call runs item with the named mapper on a worker, and returns its outputs.
+func (c *Coordinator) call(ctx context.Context, mapper string, item []byte) ([][]byte, error) {
+	c.mu.Lock()
+	c.nextTask++
+	t := &task{
+		id:     c.nextTask,
+		mapper: mapper,
+		item:   item,
+		done:   make(chan struct{}),
+	}
+	c.enqueue(t)
+	c.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return t.outputs, t.err
+	case <-ctx.Done():
+		c.cancel(t)
+		return nil, ctx.Err()
+	case <-c.quit:
+		return nil, ErrClosed
+	}
+}
+
+func (c *Coordinator) cancel(t *task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a queued task is skipped by fetch
+	t.cancelled = true
+	if ws, ok := c.workers[t.worker]; ok {
+		delete(ws.tasks, t.id)
+	}
+}
+
+// enqueue queues t and wakes up the waiting fetches, it must be called with mu held.
+func (c *Coordinator) enqueue(t *task) {
+	t.worker = ""
+	q, ok := c.queues[t.mapper]
+	if !ok {
+		q = gqueue.New[*task]()
+		c.queues[t.mapper] = q
+	}
+	_ = q.Push(t)
+
+	close(c.wakeup)
+	c.wakeup = make(chan struct{})
+}
+
+// dequeue returns the first live task of the given mappers, it must be called with mu held.
+func (c *Coordinator) dequeue(mappers []string) (*task, bool) {
+	for _, mapper := range mappers {
+		q, ok := c.queues[mapper]
+		if !ok {
+			continue
+		}
+
+		for q.Len() > 0 {
+			t, _ := q.Pop()
+			if !t.cancelled {
+				return t, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func (c *Coordinator) serve() {
+	defer c.wg.Done()
+
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		select {
+		case <-c.quit:
+			c.mu.Unlock()
+			_ = conn.Close()
+			return
+		default:
+		}
+		c.conns[conn] = struct{}{}
+		c.mu.Unlock()
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.server.ServeConn(conn)
+			c.mu.Lock()
+			delete(c.conns, conn)
+			c.mu.Unlock()
+		}()
+	}
+}
+
+// reap removes the workers that missed heartbeats, and queues their tasks again.
+func (c *Coordinator) reap() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			for id, ws := range c.workers {
+				if now.Sub(ws.lastSeen) <= missedHeartbeats*c.heartbeat {
+					continue
+				}
+
+				delete(c.workers, id)
+				for _, t := range ws.tasks {
+					c.enqueue(t)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// seen returns the live worker of id and records its heartbeat, it must be called with mu held.
+func (c *Coordinator) seen(id string) (*workerState, error) {
+	ws, ok := c.workers[id]
+	if !ok {
+		return nil, ErrUnknownWorker
+	}
+
+	ws.lastSeen = time.Now()
+	return ws, nil
+}
+
+// Register registers a worker.
+func (s *rpcService) Register(args RegisterArgs, reply *RegisterReply) error {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextWorker++
+	id := strconv.FormatUint(c.nextWorker, 10)
+	c.workers[id] = &workerState{
+		mappers:  args.Mappers,
+		lastSeen: time.Now(),
+		tasks:    make(map[uint64]*task),
+	}
+	reply.WorkerID = id
+	reply.HeartbeatInterval = c.heartbeat
+
+	return nil
+}
+
+// Heartbeat keeps a worker alive.
+func (s *rpcService) Heartbeat(args WorkerArgs, _ *struct{}) error {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.seen(args.WorkerID)
+	return err
+}
+
+// Fetch assigns a task to a worker, it waits up to a heartbeat interval for a task.
+func (s *rpcService) Fetch(args WorkerArgs, reply *TaskReply) error {
+	c := s.c
+	timer := time.NewTimer(c.heartbeat)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		ws, err := c.seen(args.WorkerID)
+		if err != nil {
+			c.mu.Unlock()
+			return err
+		}
+
+		if t, ok := c.dequeue(ws.mappers); ok {
+			t.worker = args.WorkerID
+			ws.tasks[t.id] = t
+			c.mu.Unlock()
+
+			reply.OK = true
+			reply.TaskID = t.id
+			reply.Mapper = t.mapper
+			reply.Item = t.item
+			return nil
+		}
+		wakeup := c.wakeup
+		c.mu.Unlock()
+
+		select {
+		case <-c.quit:
+			return ErrClosed
+		case <-timer.C:
+			return nil
+		case <-wakeup:
+		}
+	}
+}
+
+// Report reports the result of a task, the result of a task that's no longer
+// assigned to the worker is dropped.
+func (s *rpcService) Report(args ResultArgs, _ *struct{}) error {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ws, err := c.seen(args.WorkerID)
+	if err != nil {
+		return err
+	}
+
+	t, ok := ws.tasks[args.TaskID]
+	if !ok {
+		return nil
+	}
+
+	delete(ws.tasks, args.TaskID)
+	if args.Failed {
+		t.err = &TaskError{Mapper: t.mapper, Message: args.Err}
+	} else {
+		t.outputs = args.Outputs
+	}
+	close(t.done)
+
+	return nil
+}
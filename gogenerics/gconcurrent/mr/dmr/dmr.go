@@ -0,0 +1,70 @@
+// Package dmr runs mr style ForEach and MapReduce across processes.
+//
+// A Coordinator generates the items and reduces the outputs, and Workers connected to it
+// over net/rpc run the mappers, which are registered by name on every Worker.
+// Items and outputs are encoded with gob. A Worker that misses heartbeats is
+// considered dead, and its tasks are reassigned to the other Workers.
+package dmr
+
+import (
+	"context"
+
+	"github.com/miniLCT/gosb/gogenerics/gconcurrent/mr"
+)
+
+// ForEach runs the named mapper on the workers of c with every element generated from given generate,
+// the outputs are dropped. It returns the first error of the tasks, see MapReduce.
+func ForEach[T any](c *Coordinator, mapper string, generate mr.GenerateFunc[T], opts ...mr.Option) error {
+	return mr.MapReduceVoidCtx(func(_ context.Context, source chan<- T) {
+		generate(source)
+	}, remoteMapper[T, struct{}](c, mapper, true), func(_ context.Context, pipe <-chan struct{}, _ func(error)) {
+		for range pipe {
+		}
+	}, opts...)
+}
+
+// MapReduce runs the named mapper on the workers of c with every element generated from given generate,
+// and reduces the outputs with given reducer on the caller side.
+//
+// The options of mr apply, WithWorkers is the number of tasks sent at the same time.
+// A task fails with a *TaskError if the mapper called cancel or panicked on a worker, and
+// it waits until there's a live worker that handles the mapper, use WithContext to give up.
+func MapReduce[T, U, V any](c *Coordinator, mapper string, generate mr.GenerateFunc[T],
+	reducer mr.ReducerFunc[U, V], opts ...mr.Option) (V, error) {
+	return mr.MapReduceCtx(func(_ context.Context, source chan<- T) {
+		generate(source)
+	}, remoteMapper[T, U](c, mapper, false), func(_ context.Context, pipe <-chan U, writer mr.Writer[V],
+		cancel func(error)) {
+		reducer(pipe, writer, cancel)
+	}, opts...)
+}
+
+// remoteMapper returns a mapper that runs items on the workers of c, and writes their
+// decoded outputs unless dropOutputs.
+func remoteMapper[T, U any](c *Coordinator, mapper string, dropOutputs bool) mr.MapperCtxFunc[T, U] {
+	return func(ctx context.Context, item T, writer mr.Writer[U], cancel func(error)) {
+		data, err := encode(item)
+		if err != nil {
+			cancel(err)
+			return
+		}
+
+		outputs, err := c.call(ctx, mapper, data)
+		if err != nil {
+			cancel(err)
+			return
+		}
+		if dropOutputs {
+			return
+		}
+
+		for _, output := range outputs {
+			v, err := decode[U](output)
+			if err != nil {
+				cancel(err)
+				return
+			}
+			writer.Write(v)
+		}
+	}
+}
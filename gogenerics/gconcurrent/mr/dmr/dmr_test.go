@@ -0,0 +1,173 @@
+package dmr
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miniLCT/gosb/gogenerics/gconcurrent/mr"
+	"github.com/stretchr/testify/assert"
+)
+
+var errOdd = errors.New("odd")
+
+func generateN(n int) mr.GenerateFunc[int] {
+	return func(source chan<- int) {
+		for i := 1; i <= n; i++ {
+			source <- i
+		}
+	}
+}
+
+func sum(pipe <-chan int, writer mr.Writer[int], cancel func(error)) {
+	var total int
+	for v := range pipe {
+		total += v
+	}
+	writer.Write(total)
+}
+
+func newCoordinator(t *testing.T) *Coordinator {
+	c, err := NewCoordinator("127.0.0.1:0", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	return c
+}
+
+// startWorker runs w in the background until the returned stop func is called.
+func startWorker(t *testing.T, c *Coordinator, w *Worker) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx, c.Addr())
+	}()
+	t.Cleanup(cancel)
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func squareWorker() *Worker {
+	w := NewWorker(4)
+	Handle(w, "square", func(item int, writer mr.Writer[int], cancel func(error)) {
+		writer.Write(item * item)
+	})
+	Handle(w, "even", func(item int, writer mr.Writer[int], cancel func(error)) {
+		if item%2 == 1 {
+			cancel(errOdd)
+			return
+		}
+		writer.Write(item)
+	})
+	return w
+}
+
+func TestMapReduce(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	c := newCoordinator(t)
+	startWorker(t, c, squareWorker())
+	startWorker(t, c, squareWorker())
+	// the workers register in the background
+	assert.Eventually(func() bool {
+		return c.Workers() == 2
+	}, 5*time.Second, time.Millisecond)
+
+	total, err := MapReduce(c, "square", generateN(100), sum)
+	assert.Nil(err)
+	assert.Equal(338350, total)
+
+	_, err = MapReduce(c, "even", generateN(10), sum)
+	var te *TaskError
+	assert.ErrorAs(err, &te)
+	assert.Equal("even", te.Mapper)
+	assert.Equal(errOdd.Error(), te.Message)
+}
+
+func TestForEach(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	c := newCoordinator(t)
+	var total int64
+	w := NewWorker(2)
+	HandleForEach(w, "add", func(item int) {
+		atomic.AddInt64(&total, int64(item))
+	})
+	Handle(w, "panic", func(item int, writer mr.Writer[int], cancel func(error)) {
+		panic("boom")
+	})
+	startWorker(t, c, w)
+
+	assert.Nil(ForEach(c, "add", generateN(100)))
+	assert.Equal(int64(5050), atomic.LoadInt64(&total))
+
+	var te *TaskError
+	assert.ErrorAs(ForEach(c, "panic", generateN(1)), &te)
+	assert.Equal("panic: boom", te.Message)
+}
+
+func TestReassign(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	c := newCoordinator(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	stuck := NewWorker(1)
+	Handle(stuck, "square", func(item int, writer mr.Writer[int], cancel func(error)) {
+		close(started)
+		<-release
+		writer.Write(-1)
+	})
+	// the stuck worker can't stop before its task returns
+	stopStuck := startWorker(t, c, stuck)
+
+	result := make(chan int)
+	go func() {
+		total, err := MapReduce(c, "square", generateN(1), sum)
+		assert.Nil(err)
+		result <- total
+	}()
+
+	<-started
+	startWorker(t, c, squareWorker())
+	go stopStuck()
+
+	// the task is reassigned after the stuck worker missed heartbeats
+	select {
+	case total := <-result:
+		assert.Equal(1, total)
+	case <-time.After(5 * time.Second):
+		assert.Fail("task not reassigned")
+	}
+}
+
+func TestWaitForWorker(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	c := newCoordinator(t)
+	startWorker(t, c, squareWorker())
+
+	// nobody handles cube
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := MapReduce(c, "cube", generateN(1), sum, mr.WithContext(ctx))
+	assert.Error(err)
+
+	assert.Nil(c.Close())
+	_, err = MapReduce(c, "square", generateN(1), sum)
+	assert.ErrorIs(err, ErrClosed)
+}
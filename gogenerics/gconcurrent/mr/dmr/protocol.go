@@ -0,0 +1,92 @@
+package dmr
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// serviceName is the net/rpc service name of the Coordinator.
+const serviceName = "Coordinator"
+
+var (
+	// ErrClosed is an error that the Coordinator is closed.
+	ErrClosed = errors.New("dmr: coordinator closed")
+
+	// ErrUnknownWorker is an error that a worker is not registered, or it's
+	// considered dead after missing heartbeats.
+	ErrUnknownWorker = errors.New("dmr: unknown worker")
+
+	// ErrUnknownMapper is an error that a task asks for a mapper the worker doesn't handle.
+	ErrUnknownMapper = errors.New("dmr: unknown mapper")
+)
+
+type (
+	// TaskError is the error of a task that failed on a worker,
+	// the mapper called cancel or panicked.
+	TaskError struct {
+		Mapper  string
+		Message string
+	}
+
+	// RegisterArgs is the net/rpc request of a worker to join a Coordinator.
+	RegisterArgs struct {
+		// Mappers is the names of the mappers the worker handles.
+		Mappers []string
+	}
+
+	// RegisterReply is the net/rpc reply to RegisterArgs.
+	RegisterReply struct {
+		WorkerID string
+		// HeartbeatInterval is how often the worker must send heartbeats.
+		HeartbeatInterval time.Duration
+	}
+
+	// WorkerArgs is the net/rpc request of a worker to fetch a task or send a heartbeat.
+	WorkerArgs struct {
+		WorkerID string
+	}
+
+	// TaskReply is the net/rpc reply to a fetch, OK is false if there's no task for now.
+	TaskReply struct {
+		OK     bool
+		TaskID uint64
+		Mapper string
+		Item   []byte
+	}
+
+	// ResultArgs is the net/rpc request of a worker to report the result of a task.
+	ResultArgs struct {
+		WorkerID string
+		TaskID   uint64
+		Outputs  [][]byte
+		Failed   bool
+		Err      string
+	}
+)
+
+func (te *TaskError) Error() string {
+	return fmt.Sprintf("dmr: mapper %s: %s", te.Mapper, te.Message)
+}
+
+func encode[T any](v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decode[T any](data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// isRemote reports whether err returned by net/rpc is target returned by the Coordinator.
+func isRemote(err, target error) bool {
+	return err != nil && err.Error() == target.Error()
+}
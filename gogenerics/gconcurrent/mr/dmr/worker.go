@@ -0,0 +1,266 @@
+package dmr
+
+import (
+	"context"
+	"fmt"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/miniLCT/gosb/gogenerics/gconcurrent/mr"
+)
+
+type (
+	// Worker runs the tasks of a Coordinator with its named mappers.
+	Worker struct {
+		concurrency int
+		mappers     map[string]handler
+	}
+
+	// handler runs a task, it returns the encoded outputs, or the message of the failure.
+	handler func(item []byte) (outputs [][]byte, failure string, failed bool)
+
+	// session is a connection of a Worker to a Coordinator.
+	session struct {
+		w         *Worker
+		client    *rpc.Client
+		mu        sync.Mutex
+		id        string
+		heartbeat time.Duration
+	}
+
+	collectWriter[U any] struct {
+		mu      sync.Mutex
+		outputs [][]byte
+		err     error
+	}
+)
+
+// NewWorker returns a Worker that runs up to concurrency tasks at the same time.
+func NewWorker(concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Worker{
+		concurrency: concurrency,
+		mappers:     make(map[string]handler),
+	}
+}
+
+// Handle registers mapper with name to w, it must be called before Run.
+// The items and outputs are encoded with gob, so T and U must be gob-encodable.
+// A task fails if mapper called cancel or panicked.
+func Handle[T, U any](w *Worker, name string, mapper mr.MapperFunc[T, U]) {
+	w.mappers[name] = func(data []byte) (outputs [][]byte, failure string, failed bool) {
+		item, err := decode[T](data)
+		if err != nil {
+			return nil, err.Error(), true
+		}
+
+		var (
+			writer  collectWriter[U]
+			once    sync.Once
+			called  bool
+			message string
+		)
+		cancel := func(err error) {
+			once.Do(func() {
+				if err == nil {
+					err = mr.ErrCancelWithNil
+				}
+				called, message = true, err.Error()
+			})
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					cancel(fmt.Errorf("panic: %v", r))
+				}
+			}()
+			mapper(item, &writer, cancel)
+		}()
+
+		// a late cancel from another goroutine is ignored from now on
+		once.Do(func() {})
+		if called {
+			return nil, message, true
+		}
+		if writer.err != nil {
+			return nil, writer.err.Error(), true
+		}
+
+		return writer.outputs, "", false
+	}
+}
+
+// HandleForEach registers fn with name to w, to be used by ForEach.
+func HandleForEach[T any](w *Worker, name string, fn mr.ForEachFunc[T]) {
+	Handle(w, name, func(item T, _ mr.Writer[struct{}], _ func(error)) {
+		fn(item)
+	})
+}
+
+// Run connects w to the Coordinator at addr, and runs its tasks until ctx is done,
+// it returns nil then, or the error that the connection failed.
+// If the Coordinator considered w dead, w registers again and goes on.
+func (w *Worker) Run(ctx context.Context, addr string) error {
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s := &session{
+		w:      w,
+		client: client,
+	}
+	if _, err = s.register(""); err != nil {
+		_ = client.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1 + w.concurrency)
+	go func() {
+		defer wg.Done()
+		if err := s.keepAlive(ctx); err != nil {
+			cancel(err)
+		}
+	}()
+	for i := 0; i < w.concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			if err := s.runTasks(ctx); err != nil {
+				cancel(err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	// the pending calls return once the client is closed
+	_ = client.Close()
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != ctx.Err() {
+		return err
+	}
+	return nil
+}
+
+// register registers s again if its id is still stale, and returns the current id.
+func (s *session) register(stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.id != stale {
+		return s.id, nil
+	}
+
+	mappers := make([]string, 0, len(s.w.mappers))
+	for name := range s.w.mappers {
+		mappers = append(mappers, name)
+	}
+	var reply RegisterReply
+	if err := s.client.Call(serviceName+".Register", RegisterArgs{Mappers: mappers}, &reply); err != nil {
+		return "", err
+	}
+
+	s.id, s.heartbeat = reply.WorkerID, reply.HeartbeatInterval
+	return s.id, nil
+}
+
+func (s *session) current() (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id, s.heartbeat
+}
+
+// call calls the Coordinator as the current worker, and registers again if the worker is unknown.
+func (s *session) call(ctx context.Context, method string, args func(id string) any, reply any) error {
+	for {
+		id, _ := s.current()
+		err := s.client.Call(serviceName+"."+method, args(id), reply)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !isRemote(err, ErrUnknownWorker) {
+			return err
+		}
+		if _, err = s.register(id); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) keepAlive(ctx context.Context) error {
+	_, interval := s.current()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.call(ctx, "Heartbeat", func(id string) any {
+				return WorkerArgs{WorkerID: id}
+			}, &struct{}{}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *session) runTasks(ctx context.Context) error {
+	for ctx.Err() == nil {
+		var (
+			reply TaskReply
+			owner string
+		)
+		if err := s.call(ctx, "Fetch", func(id string) any {
+			owner = id
+			return WorkerArgs{WorkerID: id}
+		}, &reply); err != nil {
+			return err
+		}
+		if !reply.OK {
+			continue
+		}
+
+		result := ResultArgs{
+			WorkerID: owner,
+			TaskID:   reply.TaskID,
+		}
+		if h, ok := s.w.mappers[reply.Mapper]; ok {
+			result.Outputs, result.Err, result.Failed = h(reply.Item)
+		} else {
+			result.Err, result.Failed = ErrUnknownMapper.Error(), true
+		}
+
+		// a task reported by a stale id is dropped by the Coordinator, it's reassigned already
+		err := s.client.Call(serviceName+".Report", result, &struct{}{})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !isRemote(err, ErrUnknownWorker) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cw *collectWriter[U]) Write(v U) {
+	data, err := encode(v)
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if err != nil {
+		if cw.err == nil {
+			cw.err = err
+		}
+		return
+	}
+	cw.outputs = append(cw.outputs, data)
+}
@@ -0,0 +1,121 @@
+package mr
+
+import (
+	"fmt"
+	"sync"
+)
+
+type (
+	// ItemError records the failure of a single item in error-collection mode.
+	ItemError struct {
+		// Item is the element that caused the failure.
+		Item any
+		// Err is the error passed to cancel.
+		Err error
+	}
+
+	// AggregateError is returned in error-collection mode, it holds every item failure
+	// in the order they occurred. errors.Is and errors.As look through all of them.
+	AggregateError struct {
+		Errors []*ItemError
+	}
+
+	// wrappedItem is implemented by the internal wrappers around source elements,
+	// so that ItemError and item options see the element the caller passed in.
+	wrappedItem interface {
+		unwrapItem() any
+	}
+
+	errorCollector struct {
+		mu   sync.Mutex
+		errs []*ItemError
+	}
+)
+
+func (ie *ItemError) Error() string {
+	return fmt.Sprintf("item %v: %v", ie.Item, ie.Err)
+}
+
+// Unwrap returns the underlying error.
+func (ie *ItemError) Unwrap() error {
+	return ie.Err
+}
+
+func (ae *AggregateError) Error() string {
+	if len(ae.Errors) == 1 {
+		return ae.Errors[0].Error()
+	}
+
+	return fmt.Sprintf("%d items failed, first: %v", len(ae.Errors), ae.Errors[0])
+}
+
+// Unwrap returns all item errors, it makes errors.Is and errors.As work.
+func (ae *AggregateError) Unwrap() []error {
+	errs := make([]error, len(ae.Errors))
+	for i, err := range ae.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// WithCollectErrors customizes a mapreduce processing to keep going when a mapper calls cancel.
+// Every error passed to cancel by a mapper is recorded with its item, and all of them are
+// returned as an *AggregateError once the processing finished.
+// The reducer's cancel keeps the first-cancel semantics.
+func WithCollectErrors() Option {
+	return func(opts *mapReduceOptions) {
+		opts.collectErrors = true
+	}
+}
+
+// FinishAll runs fns parallelly, unlike Finish it's not cancelled on error.
+// It returns an *AggregateError whose items are the indexes of the failed fns.
+func FinishAll(fns ...func() error) error {
+	if len(fns) == 0 {
+		return nil
+	}
+
+	return MapReduceVoid(func(source chan<- int) {
+		for i := range fns {
+			source <- i
+		}
+	}, func(i int, writer Writer[any], cancel func(error)) {
+		if err := fns[i](); err != nil {
+			cancel(err)
+		}
+	}, func(pipe <-chan any, cancel func(error)) {},
+		WithWorkers(len(fns)), WithCollectErrors())
+}
+
+func (ec *errorCollector) add(item any, err error) {
+	if err == nil {
+		err = ErrCancelWithNil
+	}
+
+	ec.mu.Lock()
+	ec.errs = append(ec.errs, &ItemError{Item: unwrapItem(item), Err: err})
+	ec.mu.Unlock()
+}
+
+func (ec *errorCollector) err() error {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	if len(ec.errs) == 0 {
+		return nil
+	}
+
+	errs := make([]*ItemError, len(ec.errs))
+	copy(errs, ec.errs)
+	return &AggregateError{Errors: errs}
+}
+
+// unwrapItem returns the element the caller passed in if item is an internal wrapper.
+func unwrapItem(item any) any {
+	if wi, ok := item.(wrappedItem); ok {
+		return wi.unwrapItem()
+	}
+
+	return item
+}
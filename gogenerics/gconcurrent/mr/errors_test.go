@@ -0,0 +1,100 @@
+package mr
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codeError struct {
+	code int
+}
+
+func (ce codeError) Error() string {
+	return "code error"
+}
+
+func TestMapReduceCollectErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	val, err := MapReduce(func(source chan<- int) {
+		for i := 0; i < 10; i++ {
+			source <- i
+		}
+	}, func(item int, writer Writer[int], cancel func(error)) {
+		if item%3 == 0 {
+			cancel(codeError{code: item})
+			return
+		}
+		writer.Write(item)
+	}, func(pipe <-chan int, writer Writer[int], cancel func(error)) {
+		var sum int
+		for v := range pipe {
+			sum += v
+		}
+		writer.Write(sum)
+	}, WithCollectErrors(), WithWorkers(4))
+
+	// the reducer still sees every successful item
+	assert.Equal(1+2+4+5+7+8, val)
+
+	var ae *AggregateError
+	assert.ErrorAs(err, &ae)
+	assert.Len(ae.Errors, 4)
+	items := make([]int, 0, len(ae.Errors))
+	for _, ie := range ae.Errors {
+		items = append(items, ie.Item.(int))
+	}
+	sort.Ints(items)
+	assert.Equal([]int{0, 3, 6, 9}, items)
+
+	var ce codeError
+	assert.ErrorAs(err, &ce)
+	assert.Equal(0, ce.code%3)
+}
+
+func TestMapCollectErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	_, err := Map(context.Background(), []string{"a", "b", "c"}, func(ctx context.Context, item string) (string, error) {
+		if item == "b" {
+			return "", errDummy
+		}
+		return item, nil
+	}, WithCollectErrors())
+	assert.ErrorIs(err, errDummy)
+
+	var ae *AggregateError
+	assert.ErrorAs(err, &ae)
+	assert.Len(ae.Errors, 1)
+	assert.Equal("b", ae.Errors[0].Item)
+	assert.Equal("item b: dummy", err.Error())
+}
+
+func TestFinishAll(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var ran [3]bool
+	err := FinishAll(func() error {
+		ran[0] = true
+		return errDummy
+	}, func() error {
+		ran[1] = true
+		return nil
+	}, func() error {
+		ran[2] = true
+		return ErrReduceNoOutput
+	})
+	assert.Equal([3]bool{true, true, true}, ran)
+	assert.ErrorIs(err, errDummy)
+	assert.ErrorIs(err, ErrReduceNoOutput)
+	assert.False(errors.Is(err, ErrCancelWithNil))
+	assert.Nil(FinishAll())
+	assert.Nil(FinishAll(func() error { return nil }))
+}
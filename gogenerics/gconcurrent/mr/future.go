@@ -0,0 +1,250 @@
+package mr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoFutures is an error that Any or Race is called without futures.
+var ErrNoFutures = errors.New("no futures")
+
+type (
+	// Future is the result of an asynchronous computation, created by Async or the combinators.
+	// A panic in the computation is turned into a *PanicError.
+	Future[T any] struct {
+		parent context.Context
+		cancel context.CancelCauseFunc
+		once   sync.Once
+		done   chan struct{}
+		val    T
+		err    error
+	}
+
+	// Awaitable is implemented by every Future, it lets Await wait for futures of different types.
+	Awaitable interface {
+		// Done returns a channel that's closed when the future is settled.
+		Done() <-chan struct{}
+		// Err returns the error of the future, it's only valid after Done is closed.
+		Err() error
+		// Cancel cancels the context of the future.
+		Cancel()
+	}
+)
+
+// Async runs fn in a new goroutine, and returns the Future of its result.
+// fn gets a ctx derived from ctx, which is cancelled by Cancel, or when the future loses in a combinator.
+func Async[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f, fctx := newFuture[T](ctx)
+	go func() {
+		var (
+			val T
+			err error
+		)
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r}
+			}
+			f.settle(val, err)
+		}()
+
+		val, err = fn(fctx)
+	}()
+
+	return f
+}
+
+// Then returns a Future that runs fn with the value of f once f succeeded,
+// or fails with the error of f.
+func Then[T, U any](f *Future[T], fn func(ctx context.Context, val T) (U, error)) *Future[U] {
+	return Async(f.parent, func(ctx context.Context) (U, error) {
+		val, err := f.Get(ctx)
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+
+		return fn(ctx, val)
+	})
+}
+
+// All returns a Future of the values of fs in order, it fails on the first error and cancels the others.
+func All[T any](fs ...*Future[T]) *Future[[]T] {
+	res, ctx := newFuture[[]T](context.Background())
+	go func() {
+		vals := make([]T, len(fs))
+		settled := settledIndexes(fs)
+		for range fs {
+			select {
+			case <-ctx.Done():
+				cancelFutures(fs)
+				res.settle(nil, context.Cause(ctx))
+				return
+			case i := <-settled:
+				if err := fs[i].err; err != nil {
+					cancelFutures(fs)
+					res.settle(nil, err)
+					return
+				}
+				vals[i] = fs[i].val
+			}
+		}
+		res.settle(vals, nil)
+	}()
+
+	return res
+}
+
+// Any returns a Future of the first value of fs that succeeded, and cancels the others.
+// If all of fs failed, it fails with an *AggregateError whose items are the indexes of fs.
+func Any[T any](fs ...*Future[T]) *Future[T] {
+	return first(fs, false)
+}
+
+// Race returns a Future settled the same as the first settled one of fs, and cancels the others.
+func Race[T any](fs ...*Future[T]) *Future[T] {
+	return first(fs, true)
+}
+
+// Await waits for all the futures, and returns nil if all of them succeeded.
+// On the first error, it cancels the others and returns the error without waiting for them.
+func Await(fs ...Awaitable) error {
+	settled := settledIndexes(fs)
+	for range fs {
+		if err := fs[<-settled].Err(); err != nil {
+			for _, f := range fs {
+				f.Cancel()
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get waits for f to settle and returns its result, or returns ctx.Err() if ctx is done first.
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case <-f.done:
+		return f.val, f.err
+	}
+}
+
+// Done returns a channel that's closed when f is settled.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the error of f, it's only valid after Done is closed.
+func (f *Future[T]) Err() error {
+	return f.err
+}
+
+// Cancel cancels the context of f, the computation decides how to settle f then.
+func (f *Future[T]) Cancel() {
+	f.cancel(nil)
+}
+
+// WithTimeout returns a Future settled the same as f, or failed with context.DeadlineExceeded
+// if f is not settled in timeout, f is cancelled in that case.
+func (f *Future[T]) WithTimeout(timeout time.Duration) *Future[T] {
+	res, ctx := newFuture[T](f.parent)
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-f.done:
+			res.settle(f.val, f.err)
+		case <-timer.C:
+			f.Cancel()
+			var zero T
+			res.settle(zero, context.DeadlineExceeded)
+		case <-ctx.Done():
+			f.Cancel()
+			var zero T
+			res.settle(zero, context.Cause(ctx))
+		}
+	}()
+
+	return res
+}
+
+func newFuture[T any](ctx context.Context) (*Future[T], context.Context) {
+	f := &Future[T]{
+		parent: ctx,
+		done:   make(chan struct{}),
+	}
+	fctx, cancel := context.WithCancelCause(ctx)
+	f.cancel = cancel
+
+	return f, fctx
+}
+
+// settle sets the result of f, only the first call takes effect.
+func (f *Future[T]) settle(val T, err error) {
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+		// release the context, the computation is over
+		f.cancel(nil)
+	})
+}
+
+func first[T any](fs []*Future[T], settleOnError bool) *Future[T] {
+	res, ctx := newFuture[T](context.Background())
+	if len(fs) == 0 {
+		var zero T
+		res.settle(zero, ErrNoFutures)
+		return res
+	}
+
+	go func() {
+		var errs []*ItemError
+		settled := settledIndexes(fs)
+		for range fs {
+			select {
+			case <-ctx.Done():
+				cancelFutures(fs)
+				var zero T
+				res.settle(zero, context.Cause(ctx))
+				return
+			case i := <-settled:
+				if fs[i].err == nil || settleOnError {
+					cancelFutures(fs)
+					res.settle(fs[i].val, fs[i].err)
+					return
+				}
+				errs = append(errs, &ItemError{Item: i, Err: fs[i].err})
+			}
+		}
+
+		var zero T
+		res.settle(zero, &AggregateError{Errors: errs})
+	}()
+
+	return res
+}
+
+// settledIndexes returns a channel that receives the index of every future once it's settled.
+func settledIndexes[F Awaitable](fs []F) <-chan int {
+	settled := make(chan int, len(fs))
+	for i, f := range fs {
+		go func(i int, f F) {
+			<-f.Done()
+			settled <- i
+		}(i, f)
+	}
+
+	return settled
+}
+
+func cancelFutures[T any](fs []*Future[T]) {
+	for _, f := range fs {
+		f.Cancel()
+	}
+}
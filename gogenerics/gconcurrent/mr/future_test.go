@@ -0,0 +1,156 @@
+package mr
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sleepFor[T any](d time.Duration, val T, err error) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, context.Cause(ctx)
+		case <-time.After(d):
+			return val, err
+		}
+	}
+}
+
+func TestAsync(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	user := Async(context.Background(), sleepFor(time.Millisecond, "gosb", nil))
+	orders := Async(context.Background(), sleepFor(2*time.Millisecond, []int{1, 2}, nil))
+	assert.Nil(Await(user, orders))
+
+	name, err := user.Get(context.Background())
+	assert.Nil(err)
+	assert.Equal("gosb", name)
+	ids, err := orders.Get(context.Background())
+	assert.Nil(err)
+	assert.Equal([]int{1, 2}, ids)
+}
+
+func TestAsyncPanic(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	f := Async(context.Background(), func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	_, err := f.Get(context.Background())
+	var pe *PanicError
+	assert.ErrorAs(err, &pe)
+	assert.Equal("boom", pe.Value)
+}
+
+func TestAwaitError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	slow := Async(context.Background(), sleepFor(time.Hour, 1, nil))
+	failed := Async(context.Background(), sleepFor(time.Millisecond, "", errDummy))
+	assert.ErrorIs(Await(slow, failed), errDummy)
+
+	// the slow one is cancelled
+	_, err := slow.Get(context.Background())
+	assert.ErrorIs(err, context.Canceled)
+}
+
+func TestThen(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	f := Then(Async(context.Background(), sleepFor(time.Millisecond, 42, nil)),
+		func(ctx context.Context, v int) (string, error) {
+			return strconv.Itoa(v), nil
+		})
+	v, err := f.Get(context.Background())
+	assert.Nil(err)
+	assert.Equal("42", v)
+
+	f = Then(Async(context.Background(), sleepFor(time.Millisecond, 0, errDummy)),
+		func(ctx context.Context, v int) (string, error) {
+			return strconv.Itoa(v), nil
+		})
+	_, err = f.Get(context.Background())
+	assert.ErrorIs(err, errDummy)
+}
+
+func TestAll(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	vals, err := All(
+		Async(context.Background(), sleepFor(3*time.Millisecond, 1, nil)),
+		Async(context.Background(), sleepFor(time.Millisecond, 2, nil)),
+	).Get(context.Background())
+	assert.Nil(err)
+	assert.Equal([]int{1, 2}, vals)
+
+	slow := Async(context.Background(), sleepFor(time.Hour, 1, nil))
+	_, err = All(slow, Async(context.Background(), sleepFor(time.Millisecond, 0, errDummy))).
+		Get(context.Background())
+	assert.ErrorIs(err, errDummy)
+	_, err = slow.Get(context.Background())
+	assert.ErrorIs(err, context.Canceled)
+
+	vals, err = All[int]().Get(context.Background())
+	assert.Nil(err)
+	assert.Empty(vals)
+}
+
+func TestAnyAndRace(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	v, err := Any(
+		Async(context.Background(), sleepFor(time.Millisecond, 0, errDummy)),
+		Async(context.Background(), sleepFor(5*time.Millisecond, 2, nil)),
+	).Get(context.Background())
+	assert.Nil(err)
+	assert.Equal(2, v)
+
+	_, err = Any(
+		Async(context.Background(), sleepFor(time.Millisecond, 0, errDummy)),
+		Async(context.Background(), sleepFor(time.Millisecond, 0, errFatal)),
+	).Get(context.Background())
+	assert.ErrorIs(err, errDummy)
+	assert.ErrorIs(err, errFatal)
+
+	_, err = Race(
+		Async(context.Background(), sleepFor(time.Millisecond, 0, errDummy)),
+		Async(context.Background(), sleepFor(time.Hour, 2, nil)),
+	).Get(context.Background())
+	assert.ErrorIs(err, errDummy)
+
+	_, err = Race[int]().Get(context.Background())
+	assert.ErrorIs(err, ErrNoFutures)
+}
+
+func TestFutureWithTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	slow := Async(context.Background(), sleepFor(time.Hour, 1, nil))
+	_, err := slow.WithTimeout(5 * time.Millisecond).Get(context.Background())
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	_, err = slow.Get(context.Background())
+	assert.ErrorIs(err, context.Canceled)
+
+	v, err := Async(context.Background(), sleepFor(time.Millisecond, 1, nil)).
+		WithTimeout(time.Hour).Get(context.Background())
+	assert.Nil(err)
+	assert.Equal(1, v)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = Async(context.Background(), sleepFor(time.Hour, 1, nil)).Get(ctx)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+}
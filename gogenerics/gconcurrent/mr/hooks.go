@@ -0,0 +1,211 @@
+package mr
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultProgressInterval = time.Second
+
+type (
+	// Hooks observes a mapreduce processing, every callback is optional.
+	// Item callbacks are called in the mapper goroutines, so they must be safe for concurrent use.
+	Hooks struct {
+		// OnStart is called before an item is mapped.
+		OnStart func(item any)
+		// OnFinish is called after an item is mapped without cancel.
+		OnFinish func(item any, elapsed time.Duration)
+		// OnFail is called after an item called cancel or panicked,
+		// with retries the item fails once they're exhausted.
+		OnFail func(item any, err error, elapsed time.Duration)
+		// OnProgress is called every ProgressInterval, and once more when the processing is over.
+		OnProgress func(progress Progress)
+		// OnLimitChange is called with the current limit of workers with WithAdaptiveWorkers,
+		// once at the start and whenever it changes.
+		OnLimitChange func(limit int)
+		// ProgressInterval defaults to 1s.
+		ProgressInterval time.Duration
+	}
+
+	// Progress is a snapshot of a mapreduce processing.
+	Progress struct {
+		// Processed is the number of items that finished or failed.
+		Processed int64
+		// InFlight is the number of items being mapped.
+		InFlight int64
+		// Failed is the number of items that failed.
+		Failed int64
+		// Throughput is the number of processed items per second since the start.
+		Throughput float64
+		// QueueDepth is the number of items taken from the source but not dispatched to a mapper yet,
+		// such as the items in the priority window or waiting for the rate limiter, plus the items
+		// buffered in the source channel, e.g. between pipeline stages.
+		QueueDepth int
+		// Elapsed is the duration since the start.
+		Elapsed time.Duration
+	}
+
+	hookStats struct {
+		hooks     *Hooks
+		start     time.Time
+		queue     func() int
+		processed int64
+		inFlight  int64
+		failed    int64
+	}
+)
+
+// WithHooks customizes a mapreduce processing to report item events and progress to hooks.
+func WithHooks(hooks Hooks) Option {
+	return func(opts *mapReduceOptions) {
+		opts.hooks = &hooks
+	}
+}
+
+// FinishWithHooks is like Finish, and reports every fn to hooks, the item is the index of fn.
+func FinishWithHooks(hooks Hooks, fns ...func() error) error {
+	if len(fns) == 0 {
+		return nil
+	}
+
+	return MapReduceVoid(func(source chan<- int) {
+		for i := range fns {
+			source <- i
+		}
+	}, func(i int, writer Writer[any], cancel func(error)) {
+		if err := fns[i](); err != nil {
+			cancel(err)
+		}
+	}, func(pipe <-chan any, cancel func(error)) {},
+		WithWorkers(len(fns)), WithHooks(hooks))
+}
+
+// newHookStats returns nil if hooks is nil, queue returns the number of buffered source items.
+func newHookStats(hooks *Hooks, queue func() int) *hookStats {
+	if hooks == nil {
+		return nil
+	}
+
+	return &hookStats{
+		hooks: hooks,
+		start: time.Now(),
+		queue: queue,
+	}
+}
+
+// report calls OnProgress periodically until ctx is done or the returned stop func is called,
+// and once more at the end. stop waits for the last report.
+func (hs *hookStats) report(ctx context.Context) (stop func()) {
+	if hs == nil || hs.hooks.OnProgress == nil {
+		return func() {}
+	}
+
+	interval := hs.hooks.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				hs.hooks.OnProgress(hs.snapshot())
+				return
+			case <-quit:
+				hs.hooks.OnProgress(hs.snapshot())
+				return
+			case <-ticker.C:
+				hs.hooks.OnProgress(hs.snapshot())
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			close(quit)
+		})
+		<-done
+	}
+}
+
+func (hs *hookStats) snapshot() Progress {
+	elapsed := time.Since(hs.start)
+	processed := atomic.LoadInt64(&hs.processed)
+	progress := Progress{
+		Processed: processed,
+		InFlight:  atomic.LoadInt64(&hs.inFlight),
+		Failed:    atomic.LoadInt64(&hs.failed),
+		Elapsed:   elapsed,
+	}
+	if elapsed > 0 {
+		progress.Throughput = float64(processed) / elapsed.Seconds()
+	}
+	if hs.queue != nil {
+		progress.QueueDepth = hs.queue()
+	}
+
+	return progress
+}
+
+// track runs fn for item and reports it, fn reports the failure of item with fail.
+func (hs *hookStats) track(item any, fn func(fail func(error))) {
+	item = unwrapItem(item)
+	if hs.hooks.OnStart != nil {
+		hs.hooks.OnStart(item)
+	}
+	atomic.AddInt64(&hs.inFlight, 1)
+
+	var at attempt
+	start := time.Now()
+	defer func() {
+		r := recover()
+		elapsed := time.Since(start)
+		called, err := at.result()
+		if r != nil {
+			called, err = true, &PanicError{Value: r}
+		} else if called && err == nil {
+			err = ErrCancelWithNil
+		}
+
+		atomic.AddInt64(&hs.inFlight, -1)
+		atomic.AddInt64(&hs.processed, 1)
+		if called {
+			atomic.AddInt64(&hs.failed, 1)
+			if hs.hooks.OnFail != nil {
+				hs.hooks.OnFail(item, err, elapsed)
+			}
+		} else if hs.hooks.OnFinish != nil {
+			hs.hooks.OnFinish(item, elapsed)
+		}
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	fn(at.cancel)
+}
+
+// hookMapper wraps mapper to report every item to hs, it returns mapper itself if hs is nil.
+func hookMapper[T, U any](mapper MapperCtxFunc[T, U], hs *hookStats) MapperCtxFunc[T, U] {
+	if hs == nil {
+		return mapper
+	}
+
+	return func(ctx context.Context, item T, writer Writer[U], cancel func(error)) {
+		hs.track(item, func(fail func(error)) {
+			mapper(ctx, item, writer, func(err error) {
+				fail(err)
+				cancel(err)
+			})
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package mr
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedHooks struct {
+	started  int32
+	finished int32
+	failed   int32
+	mu       sync.Mutex
+	progress []Progress
+}
+
+func (rh *recordedHooks) hooks() Hooks {
+	return Hooks{
+		OnStart: func(item any) {
+			atomic.AddInt32(&rh.started, 1)
+		},
+		OnFinish: func(item any, elapsed time.Duration) {
+			atomic.AddInt32(&rh.finished, 1)
+		},
+		OnFail: func(item any, err error, elapsed time.Duration) {
+			atomic.AddInt32(&rh.failed, 1)
+		},
+		OnProgress: func(progress Progress) {
+			rh.mu.Lock()
+			rh.progress = append(rh.progress, progress)
+			rh.mu.Unlock()
+		},
+		ProgressInterval: time.Millisecond,
+	}
+}
+
+func (rh *recordedHooks) last() Progress {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	return rh.progress[len(rh.progress)-1]
+}
+
+func TestMapHooks(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var rh recordedHooks
+	failedItems := make(chan any, 10)
+	hooks := rh.hooks()
+	onFail := hooks.OnFail
+	hooks.OnFail = func(item any, err error, elapsed time.Duration) {
+		failedItems <- item
+		onFail(item, err, elapsed)
+	}
+
+	_, err := Map(context.Background(), []int{1, 2, 3, 4, 5, 6}, func(ctx context.Context, item int) (int, error) {
+		time.Sleep(2 * time.Millisecond)
+		if item == 4 {
+			return 0, errDummy
+		}
+		return item, nil
+	}, WithHooks(hooks), WithCollectErrors(), WithWorkers(2))
+	assert.ErrorIs(err, errDummy)
+	assert.Equal(int32(6), atomic.LoadInt32(&rh.started))
+	assert.Equal(int32(5), atomic.LoadInt32(&rh.finished))
+	assert.Equal(int32(1), atomic.LoadInt32(&rh.failed))
+	assert.Equal(4, <-failedItems)
+
+	last := rh.last()
+	assert.Equal(int64(6), last.Processed)
+	assert.Equal(int64(1), last.Failed)
+	assert.Equal(int64(0), last.InFlight)
+	assert.True(last.Throughput > 0)
+}
+
+func TestForEachHooks(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var rh recordedHooks
+	ForEach(func(source chan<- int) {
+		for i := 0; i < 10; i++ {
+			source <- i
+		}
+	}, func(item int) {}, WithHooks(rh.hooks()))
+	assert.Equal(int32(10), atomic.LoadInt32(&rh.finished))
+	assert.Equal(int64(10), rh.last().Processed)
+}
+
+func TestHooksQueueDepth(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	maxDepth := func(rh *recordedHooks) int {
+		rh.mu.Lock()
+		defer rh.mu.Unlock()
+		var depth int
+		for _, p := range rh.progress {
+			if p.QueueDepth > depth {
+				depth = p.QueueDepth
+			}
+		}
+		return depth
+	}
+
+	// the items wait in the priority window
+	var rh recordedHooks
+	_, err := Map(context.Background(), make([]int, 200), func(ctx context.Context, item int) (int, error) {
+		time.Sleep(100 * time.Microsecond)
+		return item, nil
+	}, WithHooks(rh.hooks()), WithWorkers(1), WithPriority(func(item int) int { return item }, 0))
+	assert.Nil(err)
+	assert.Greater(maxDepth(&rh), 1)
+	assert.Equal(0, rh.last().QueueDepth)
+
+	// an item waits for the rate limiter
+	var rl recordedHooks
+	ForEach(func(source chan<- int) {
+		for i := 0; i < 20; i++ {
+			source <- i
+		}
+	}, func(item int) {}, WithHooks(rl.hooks()), WithRateLimit(NewLimiter(500, 1)))
+	assert.Greater(maxDepth(&rl), 0)
+	assert.Equal(0, rl.last().QueueDepth)
+}
+
+func TestPipelineHooks(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	reports := func(rh *recordedHooks) int {
+		rh.mu.Lock()
+		defer rh.mu.Unlock()
+		return len(rh.progress)
+	}
+	newPipeline := func(rh *recordedHooks) *Pipeline[int] {
+		return AddStage(PipelineFromSlice(make([]int, 50)), func(ctx context.Context, item int,
+			writer Writer[int], cancel func(error)) {
+			writer.Write(item)
+		}, WithHooks(rh.hooks()), WithWorkers(4))
+	}
+
+	// the last report of a stage is made before the sink returns, and no report after it
+	var rc recordedHooks
+	out, err := newPipeline(&rc).Collect(context.Background())
+	assert.Nil(err)
+	assert.Len(out, 50)
+	n := reports(&rc)
+	assert.Greater(n, 0)
+	assert.Equal(int64(50), rc.last().Processed)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(n, reports(&rc))
+
+	var rs recordedHooks
+	stream, wait := newPipeline(&rs).Stream(context.Background())
+	for range stream {
+	}
+	assert.Nil(wait())
+	n = reports(&rs)
+	assert.Greater(n, 0)
+	assert.Equal(int64(50), rs.last().Processed)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(n, reports(&rs))
+}
+
+func TestFinishWithHooks(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	latencies := make(map[any]time.Duration)
+	err := FinishWithHooks(Hooks{
+		OnFinish: func(item any, elapsed time.Duration) {
+			mu.Lock()
+			latencies[item] = elapsed
+			mu.Unlock()
+		},
+	}, func() error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}, func() error {
+		return nil
+	})
+	assert.Nil(err)
+	assert.Len(latencies, 2)
+	assert.True(latencies[0] >= 5*time.Millisecond)
+}
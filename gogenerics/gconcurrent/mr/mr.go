@@ -3,8 +3,10 @@ package mr
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/miniLCT/gosb/gogenerics/gconstraints"
 )
@@ -44,6 +46,21 @@ type (
 	// Use cancel func to cancel the processing.
 	VoidReducerFunc[U any] func(pipe <-chan U, cancel func(error))
 
+	// ForEachCtxFunc is like ForEachFunc, but receives the pipeline context.
+	ForEachCtxFunc[T any] func(ctx context.Context, item T)
+
+	// GenerateCtxFunc is like GenerateFunc, but receives the pipeline context.
+	GenerateCtxFunc[T any] func(ctx context.Context, source chan<- T)
+
+	// MapperCtxFunc is like MapperFunc, but receives the pipeline context.
+	MapperCtxFunc[T, U any] func(ctx context.Context, item T, writer Writer[U], cancel func(error))
+
+	// ReducerCtxFunc is like ReducerFunc, but receives the pipeline context.
+	ReducerCtxFunc[U, V any] func(ctx context.Context, pipe <-chan U, writer Writer[V], cancel func(error))
+
+	// VoidReducerCtxFunc is like VoidReducerFunc, but receives the pipeline context.
+	VoidReducerCtxFunc[U any] func(ctx context.Context, pipe <-chan U, cancel func(error))
+
 	// Option defines the method to customize the mapreduce.
 	Option func(opts *mapReduceOptions)
 
@@ -55,17 +72,43 @@ type (
 		collector chan<- U
 		doneChan  <-chan struct{}
 		workers   int
+		limiter   *Limiter
+		weight    func(item any) int
+		priority  priorityOptions
+		adaptive  *adaptiveLimit
+		// pending counts the items taken from source but not dispatched to a mapper yet, it may be nil.
+		pending *atomic.Int64
 	}
 
 	mapReduceOptions struct {
-		ctx     context.Context
-		workers int
+		ctx                context.Context
+		workers            int
+		buffer             int
+		reducers           int
+		collectErrors      bool
+		rejectWhenFull     bool
+		limiter            *Limiter
+		weight             func(item any) int
+		hooks              *Hooks
+		priority           priorityOptions
+		adaptive           *adaptiveOptions
+		checkpointInterval time.Duration
+		// legacyCtxErr makes the non-Ctx APIs return context.DeadlineExceeded
+		// when ctx is done, whatever its cause, as they always did.
+		legacyCtxErr bool
+		retryOptions
 	}
 
 	// Writer interface wraps Write method.
 	Writer[T any] interface {
 		Write(v T)
 	}
+
+	// PanicError is the cause of the pipeline context when
+	// a generator, mapper or reducer panicked.
+	PanicError struct {
+		Value any
+	}
 )
 
 // Finish runs fns parallelly, cancelled on any error.
@@ -101,29 +144,80 @@ func FinishVoid(fns ...func()) {
 	}, WithWorkers(len(fns)))
 }
 
+// FinishCtx runs fns parallelly, cancelled on any error.
+// The ctx passed to fns is cancelled as soon as any of them fails.
+func FinishCtx(ctx context.Context, fns ...func(ctx context.Context) error) error {
+	if len(fns) == 0 {
+		return nil
+	}
+
+	return MapReduceVoidCtx(func(_ context.Context, source chan<- func(context.Context) error) {
+		for _, fn := range fns {
+			source <- fn
+		}
+	}, func(ctx context.Context, fn func(context.Context) error, writer Writer[any], cancel func(error)) {
+		if err := fn(ctx); err != nil {
+			cancel(err)
+		}
+	}, func(_ context.Context, pipe <-chan any, cancel func(error)) {},
+		WithContext(ctx), WithWorkers(len(fns)))
+}
+
 // ForEach maps all elements from given generate but no output.
 func ForEach[T any](generate GenerateFunc[T], mapper ForEachFunc[T], opts ...Option) {
+	ForEachCtx(func(_ context.Context, source chan<- T) {
+		generate(source)
+	}, func(_ context.Context, item T) {
+		mapper(item)
+	}, opts...)
+}
+
+// ForEachCtx maps all elements from given generate but no output.
+// The pipeline context passed to generate and mapper is cancelled
+// on panic, on parent cancellation, or when ForEachCtx returns.
+func ForEachCtx[T any](generate GenerateCtxFunc[T], mapper ForEachCtxFunc[T], opts ...Option) {
 	options := buildOptions(opts...)
+	ctx, cancel := context.WithCancelCause(options.ctx)
+	defer cancel(nil)
+
 	panicChan := &onceChan{channel: make(chan any)}
-	source := buildSource(generate, panicChan)
+	source := buildSource(ctx, generate, panicChan)
 	collector := make(chan any)
 	done := make(chan struct{})
 
+	pending := new(atomic.Int64)
+	hs := newHookStats(options.hooks, func() int {
+		return len(source) + int(pending.Load())
+	})
+	defer hs.report(ctx)()
+	al := newAdaptiveLimit(options)
+	fn := hookMapper(adaptiveMapper(func(ctx context.Context, item T, _ Writer[any], _ func(error)) {
+		mapper(ctx, item)
+	}, al), hs)
+
 	go executeMappers(mapperContext[T, any]{
-		ctx: options.ctx,
-		mapper: func(item T, _ Writer[any]) {
-			mapper(item)
+		ctx: ctx,
+		mapper: func(item T, w Writer[any]) {
+			ictx, icancel := options.itemContext(ctx)
+			defer icancel()
+			fn(ictx, item, w, func(error) {})
 		},
 		source:    source,
 		panicChan: panicChan,
 		collector: collector,
 		doneChan:  done,
 		workers:   options.workers,
+		limiter:   options.limiter,
+		weight:    options.weight,
+		priority:  options.priority,
+		adaptive:  al,
+		pending:   pending,
 	})
 
 	for {
 		select {
 		case v := <-panicChan.channel:
+			cancel(&PanicError{Value: v})
 			panic(v)
 		case _, ok := <-collector:
 			if !ok {
@@ -135,24 +229,63 @@ func ForEach[T any](generate GenerateFunc[T], mapper ForEachFunc[T], opts ...Opt
 
 // MapReduce maps all elements generated from given generate func,
 // and reduces the output elements with given reducer.
+// It returns context.DeadlineExceeded if the ctx of WithContext is done, even if it's cancelled.
 func MapReduce[T, U, V any](generate GenerateFunc[T], mapper MapperFunc[T, U], reducer ReducerFunc[U, V],
 	opts ...Option) (V, error) {
+	return MapReduceCtx(func(_ context.Context, source chan<- T) {
+		generate(source)
+	}, withoutCtxMapper(mapper), withoutCtxReducer(reducer), withLegacyCtxErr(opts)...)
+}
+
+// MapReduceCtx maps all elements generated from given generate func,
+// and reduces the output elements with given reducer.
+// The pipeline context passed to generate, mapper and reducer is cancelled
+// on cancel(err), on panic, on parent cancellation, or when MapReduceCtx returns.
+// Unlike MapReduce, it returns context.Cause of the parent ctx if it's done.
+func MapReduceCtx[T, U, V any](generate GenerateCtxFunc[T], mapper MapperCtxFunc[T, U], reducer ReducerCtxFunc[U, V],
+	opts ...Option) (V, error) {
+	options := buildOptions(opts...)
+	ctx, cancel := context.WithCancelCause(options.ctx)
+	defer cancel(nil)
+
 	panicChan := &onceChan{channel: make(chan any)}
-	source := buildSource(generate, panicChan)
-	return mapReduceWithPanicChan(source, panicChan, mapper, reducer, opts...)
+	source := buildSource(ctx, generate, panicChan)
+	return mapReduceWithPanicChan(ctx, cancel, source, panicChan, mapper, reducer, options)
 }
 
 // MapReduceChan maps all elements from source, and reduce the output elements with given reducer.
 func MapReduceChan[T, U, V any](source <-chan T, mapper MapperFunc[T, U], reducer ReducerFunc[U, V],
 	opts ...Option) (V, error) {
+	return MapReduceChanCtx(source, withoutCtxMapper(mapper), withoutCtxReducer(reducer), withLegacyCtxErr(opts)...)
+}
+
+// MapReduceChanCtx maps all elements from source, and reduce the output elements with given reducer.
+// The pipeline context is cancelled the same way as in MapReduceCtx.
+func MapReduceChanCtx[T, U, V any](source <-chan T, mapper MapperCtxFunc[T, U], reducer ReducerCtxFunc[U, V],
+	opts ...Option) (V, error) {
+	options := buildOptions(opts...)
+	ctx, cancel := context.WithCancelCause(options.ctx)
+	defer cancel(nil)
+
 	panicChan := &onceChan{channel: make(chan any)}
-	return mapReduceWithPanicChan(source, panicChan, mapper, reducer, opts...)
+	return mapReduceWithPanicChan(ctx, cancel, source, panicChan, mapper, reducer, options)
 }
 
 // mapReduceWithPanicChan maps all elements from source, and reduce the output elements with given reducer.
-func mapReduceWithPanicChan[T, U, V any](source <-chan T, panicChan *onceChan, mapper MapperFunc[T, U],
-	reducer ReducerFunc[U, V], opts ...Option) (val V, err error) {
-	options := buildOptions(opts...)
+// ctx is the pipeline context, it's cancelled by cancelCtx on any cancel or panic.
+// With WithCollectErrors, the reduced value is returned along with the *AggregateError.
+func mapReduceWithPanicChan[T, U, V any](ctx context.Context, cancelCtx context.CancelCauseFunc, source <-chan T,
+	panicChan *onceChan, mapper MapperCtxFunc[T, U], reducer ReducerCtxFunc[U, V],
+	options *mapReduceOptions) (val V, err error) {
+	al := newAdaptiveLimit(options)
+	mapper = retryMapper(adaptiveMapper(mapper, al), options)
+	pending := new(atomic.Int64)
+	hs := newHookStats(options.hooks, func() int {
+		return len(source) + int(pending.Load())
+	})
+	mapper = hookMapper(mapper, hs)
+	defer hs.report(ctx)()
+
 	// output is used to write the final result
 	output := make(chan V)
 	defer func() {
@@ -163,13 +296,15 @@ func mapReduceWithPanicChan[T, U, V any](source <-chan T, panicChan *onceChan, m
 	}()
 
 	// collector is used to collect data from mapper, and consume in reducer
-	collector := make(chan U, options.workers)
+	collector := make(chan U, options.bufferSize())
 	// if done is closed, all mappers and reducer should stop processing
 	done := make(chan struct{})
 	writer := newGuardedWriter(options.ctx, output, done)
 	var closeOnce sync.Once
 	// use atomic type to avoid data race
 	var retErr gconstraints.AtomicError
+	// collected is only used with WithCollectErrors
+	var collected errorCollector
 	finish := func() {
 		closeOnce.Do(func() {
 			close(done)
@@ -183,6 +318,8 @@ func mapReduceWithPanicChan[T, U, V any](source <-chan T, panicChan *onceChan, m
 			retErr.Set(ErrCancelWithNil)
 		}
 
+		// cancel the pipeline context first, so that a ctx-aware generator can stop before draining
+		cancelCtx(retErr.Load())
 		drain(source)
 		finish()
 	})
@@ -196,26 +333,43 @@ func mapReduceWithPanicChan[T, U, V any](source <-chan T, panicChan *onceChan, m
 			finish()
 		}()
 
-		reducer(collector, writer, cancel)
+		reducer(ctx, collector, writer, cancel)
 	}()
 
 	go executeMappers(mapperContext[T, U]{
-		ctx: options.ctx,
+		ctx: ctx,
 		mapper: func(item T, w Writer[U]) {
-			mapper(item, w, cancel)
+			if !options.collectErrors {
+				mapper(ctx, item, w, cancel)
+				return
+			}
+
+			mapper(ctx, item, w, func(err error) {
+				collected.add(item, err)
+			})
 		},
 		source:    source,
 		panicChan: panicChan,
 		collector: collector,
 		doneChan:  done,
 		workers:   options.workers,
+		limiter:   options.limiter,
+		weight:    options.weight,
+		priority:  options.priority,
+		adaptive:  al,
+		pending:   pending,
 	})
 
 	select {
 	case <-options.ctx.Done():
-		cancel(context.DeadlineExceeded)
-		err = context.DeadlineExceeded
+		if options.legacyCtxErr {
+			err = context.DeadlineExceeded
+		} else {
+			err = context.Cause(options.ctx)
+		}
+		cancel(err)
 	case v := <-panicChan.channel:
+		cancelCtx(&PanicError{Value: v})
 		// drain output here, otherwise for loop panic in defer
 		drain(output)
 		panic(v)
@@ -227,6 +381,9 @@ func mapReduceWithPanicChan[T, U, V any](source <-chan T, panicChan *onceChan, m
 		} else {
 			err = ErrReduceNoOutput
 		}
+		if cerr := collected.err(); cerr != nil && (err == nil || err == ErrReduceNoOutput) {
+			err = cerr
+		}
 	}
 
 	return
@@ -236,16 +393,36 @@ func mapReduceWithPanicChan[T, U, V any](source <-chan T, panicChan *onceChan, m
 // and reduce the output elements with given reducer.
 func MapReduceVoid[T, U any](generate GenerateFunc[T], mapper MapperFunc[T, U],
 	reducer VoidReducerFunc[U], opts ...Option) error {
-	_, err := MapReduce(generate, mapper, func(input <-chan U, writer Writer[any], cancel func(error)) {
+	return MapReduceVoidCtx(func(_ context.Context, source chan<- T) {
+		generate(source)
+	}, withoutCtxMapper(mapper), func(_ context.Context, input <-chan U, cancel func(error)) {
 		reducer(input, cancel)
+	}, withLegacyCtxErr(opts)...)
+}
+
+// MapReduceVoidCtx is like MapReduceVoid, but the callbacks receive the pipeline context.
+func MapReduceVoidCtx[T, U any](generate GenerateCtxFunc[T], mapper MapperCtxFunc[T, U],
+	reducer VoidReducerCtxFunc[U], opts ...Option) error {
+	_, err := MapReduceCtx(generate, mapper, func(ctx context.Context, input <-chan U, writer Writer[any],
+		cancel func(error)) {
+		reducer(ctx, input, cancel)
 	}, opts...)
-	if errors.Is(err, ErrReduceNoOutput) {
+	// an *AggregateError may hold ErrReduceNoOutput cancelled by a mapper, keep it
+	var ae *AggregateError
+	if errors.Is(err, ErrReduceNoOutput) && !errors.As(err, &ae) {
 		return nil
 	}
 
 	return err
 }
 
+// withLegacyCtxErr appends the option of the non-Ctx APIs to opts.
+func withLegacyCtxErr(opts []Option) []Option {
+	return append(opts[:len(opts):len(opts)], func(opts *mapReduceOptions) {
+		opts.legacyCtxErr = true
+	})
+}
+
 // WithContext customizes a mapreduce processing accepts a given ctx.
 func WithContext(ctx context.Context) Option {
 	return func(opts *mapReduceOptions) {
@@ -264,6 +441,25 @@ func WithWorkers(workers int) Option {
 	}
 }
 
+// WithBuffer customizes the buffer size of the channel that mappers write to,
+// it defaults to the number of workers.
+func WithBuffer(size int) Option {
+	return func(opts *mapReduceOptions) {
+		if size < 0 {
+			size = 0
+		}
+		opts.buffer = size
+	}
+}
+
+func (opts *mapReduceOptions) bufferSize() int {
+	if opts.buffer < 0 {
+		return opts.workers
+	}
+
+	return opts.buffer
+}
+
 func buildOptions(opts ...Option) *mapReduceOptions {
 	options := newOptions()
 	for _, opt := range opts {
@@ -273,7 +469,7 @@ func buildOptions(opts ...Option) *mapReduceOptions {
 	return options
 }
 
-func buildSource[T any](generate GenerateFunc[T], panicChan *onceChan) chan T {
+func buildSource[T any](ctx context.Context, generate GenerateCtxFunc[T], panicChan *onceChan) chan T {
 	source := make(chan T)
 	go func() {
 		defer func() {
@@ -283,7 +479,7 @@ func buildSource[T any](generate GenerateFunc[T], panicChan *onceChan) chan T {
 			close(source)
 		}()
 
-		generate(source)
+		generate(ctx, source)
 	}()
 
 	return source
@@ -298,6 +494,10 @@ func drain[T any](channel <-chan T) {
 }
 
 func executeMappers[T, U any](mCtx mapperContext[T, U]) {
+	if mCtx.priority.priority != nil {
+		mCtx.source = prioritize(mCtx.source, mCtx.ctx.Done(), mCtx.priority, mCtx.pending)
+	}
+
 	var wg sync.WaitGroup
 	defer func() {
 		wg.Wait()
@@ -320,7 +520,37 @@ func executeMappers[T, U any](mCtx mapperContext[T, U]) {
 				<-pool
 				return
 			}
+			if mCtx.priority.priority == nil {
+				// prioritize counts the items it takes
+				mCtx.queue(1)
+			}
+
+			// a heavy item takes more slots, the first one is already taken
+			weight := mCtx.weightOf(item)
+			for i := 1; i < weight; i++ {
+				select {
+				case <-mCtx.ctx.Done():
+					mCtx.queue(-1)
+					return
+				case <-mCtx.doneChan:
+					mCtx.queue(-1)
+					return
+				case pool <- struct{}{}:
+				}
+			}
+			if !mCtx.adaptive.acquire(mCtx.ctx, mCtx.doneChan) {
+				mCtx.queue(-1)
+				return
+			}
+			if mCtx.limiter != nil {
+				if err := mCtx.limiter.Wait(mCtx.ctx); err != nil {
+					mCtx.adaptive.release()
+					mCtx.queue(-1)
+					return
+				}
+			}
 
+			mCtx.queue(-1)
 			wg.Add(1)
 			go func() {
 				defer func() {
@@ -328,8 +558,11 @@ func executeMappers[T, U any](mCtx mapperContext[T, U]) {
 						atomic.AddInt32(&failed, 1)
 						mCtx.panicChan.write(r)
 					}
+					mCtx.adaptive.release()
 					wg.Done()
-					<-pool
+					for i := 0; i < weight; i++ {
+						<-pool
+					}
 				}()
 
 				mCtx.mapper(item, writer)
@@ -338,10 +571,47 @@ func executeMappers[T, U any](mCtx mapperContext[T, U]) {
 	}
 }
 
+// queue adds delta to the pending items.
+func (mCtx mapperContext[T, U]) queue(delta int64) {
+	if mCtx.pending != nil {
+		mCtx.pending.Add(delta)
+	}
+}
+
+// weightOf returns the number of worker slots item takes, in [1, workers].
+func (mCtx mapperContext[T, U]) weightOf(item T) int {
+	if mCtx.weight == nil {
+		return 1
+	}
+
+	weight := mCtx.weight(item)
+	if weight < 1 {
+		return 1
+	}
+	if weight > mCtx.workers {
+		return mCtx.workers
+	}
+
+	return weight
+}
+
 func newOptions() *mapReduceOptions {
 	return &mapReduceOptions{
 		ctx:     context.Background(),
 		workers: defaultWorkers,
+		buffer:  -1,
+	}
+}
+
+func withoutCtxMapper[T, U any](mapper MapperFunc[T, U]) MapperCtxFunc[T, U] {
+	return func(_ context.Context, item T, writer Writer[U], cancel func(error)) {
+		mapper(item, writer, cancel)
+	}
+}
+
+func withoutCtxReducer[U, V any](reducer ReducerFunc[U, V]) ReducerCtxFunc[U, V] {
+	return func(_ context.Context, pipe <-chan U, writer Writer[V], cancel func(error)) {
+		reducer(pipe, writer, cancel)
 	}
 }
 
@@ -354,6 +624,10 @@ func once(fn func(error)) func(error) {
 	}
 }
 
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("mapreduce panic: %v", pe.Value)
+}
+
 type guardedWriter[T any] struct {
 	ctx     context.Context
 	channel chan<- T
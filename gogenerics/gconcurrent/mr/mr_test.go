@@ -1 +1,254 @@
 package mr
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errDummy = errors.New("dummy")
+
+type ctxKey struct{}
+
+func TestMapReduce(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	val, err := MapReduce(func(source chan<- int) {
+		for i := 1; i <= 10; i++ {
+			source <- i
+		}
+	}, func(item int, writer Writer[int], cancel func(error)) {
+		writer.Write(item * item)
+	}, func(pipe <-chan int, writer Writer[int], cancel func(error)) {
+		var sum int
+		for v := range pipe {
+			sum += v
+		}
+		writer.Write(sum)
+	})
+	assert.Nil(err)
+	assert.Equal(385, val)
+}
+
+func TestMapReduceCtxCancelled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	cause := make(chan error, 1)
+	started := make(chan struct{})
+	_, err := MapReduceCtx(func(ctx context.Context, source chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case source <- i:
+			}
+		}
+	}, func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		switch item {
+		case 0:
+			<-started
+			cancel(errDummy)
+		case 1:
+			close(started)
+			<-ctx.Done()
+			cause <- context.Cause(ctx)
+		default:
+			<-ctx.Done()
+		}
+	}, func(ctx context.Context, pipe <-chan int, writer Writer[int], cancel func(error)) {
+		drain(pipe)
+	}, WithWorkers(4))
+	assert.ErrorIs(err, errDummy)
+	assert.ErrorIs(<-cause, errDummy)
+}
+
+func TestMapReduceCtxParentValue(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	parent := context.WithValue(context.Background(), ctxKey{}, "gosb")
+	val, err := MapReduceCtx(func(ctx context.Context, source chan<- string) {
+		source <- ctx.Value(ctxKey{}).(string)
+	}, func(ctx context.Context, item string, writer Writer[string], cancel func(error)) {
+		writer.Write(item + ctx.Value(ctxKey{}).(string))
+	}, func(ctx context.Context, pipe <-chan string, writer Writer[string], cancel func(error)) {
+		for v := range pipe {
+			writer.Write(v)
+		}
+	}, WithContext(parent))
+	assert.Nil(err)
+	assert.Equal("gosbgosb", val)
+}
+
+func TestMapReduceCtxParentDone(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	run := func(parent context.Context) error {
+		_, err := MapReduceCtx(func(ctx context.Context, source chan<- int) {
+			for i := 0; ; i++ {
+				select {
+				case <-ctx.Done():
+					return
+				case source <- i:
+				}
+			}
+		}, func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+			<-ctx.Done()
+		}, func(ctx context.Context, pipe <-chan int, writer Writer[int], cancel func(error)) {
+			drain(pipe)
+		}, WithContext(parent), WithWorkers(2))
+		return err
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	assert.ErrorIs(run(parent), context.Canceled)
+
+	parent, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(run(parent), context.DeadlineExceeded)
+
+	causeParent, causeCancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { causeCancel(errDummy) })
+	assert.ErrorIs(run(causeParent), errDummy)
+
+	voidParent, voidCancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, voidCancel)
+	assert.ErrorIs(MapReduceVoidCtx(func(ctx context.Context, source chan<- int) {
+		source <- 1
+	}, func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		<-ctx.Done()
+	}, func(ctx context.Context, pipe <-chan int, cancel func(error)) {
+		drain(pipe)
+	}, WithContext(voidParent)), context.Canceled)
+}
+
+func TestMapReduceParentCancelled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// the non-Ctx APIs keep returning DeadlineExceeded on a cancelled ctx, whatever the cause
+	newParent := func() context.Context {
+		parent, cancel := context.WithCancelCause(context.Background())
+		time.AfterFunc(10*time.Millisecond, func() { cancel(errDummy) })
+		return parent
+	}
+
+	parent := newParent()
+	_, err := MapReduce(func(source chan<- int) {
+		source <- 1
+	}, func(item int, writer Writer[int], cancel func(error)) {
+		<-parent.Done()
+	}, func(pipe <-chan int, writer Writer[int], cancel func(error)) {
+		drain(pipe)
+	}, WithContext(parent))
+	assert.Equal(context.DeadlineExceeded, err)
+
+	voidParent := newParent()
+	assert.Equal(context.DeadlineExceeded, MapReduceVoid(func(source chan<- int) {
+		source <- 1
+	}, func(item int, writer Writer[int], cancel func(error)) {
+		<-voidParent.Done()
+	}, func(pipe <-chan int, cancel func(error)) {
+		drain(pipe)
+	}, WithContext(voidParent)))
+
+	chanParent := newParent()
+	source := make(chan int, 1)
+	source <- 1
+	close(source)
+	_, err = MapReduceChan(source, func(item int, writer Writer[int], cancel func(error)) {
+		<-chanParent.Done()
+	}, func(pipe <-chan int, writer Writer[int], cancel func(error)) {
+		drain(pipe)
+	}, WithContext(chanParent))
+	assert.Equal(context.DeadlineExceeded, err)
+}
+
+func TestMapReduceCtxPanic(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	cause := make(chan error, 1)
+	started := make(chan struct{})
+	assert.Panics(func() {
+		_ = MapReduceVoidCtx(func(ctx context.Context, source chan<- int) {
+			source <- 0
+			source <- 1
+		}, func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+			if item == 0 {
+				<-started
+				panic("boom")
+			}
+			close(started)
+			<-ctx.Done()
+			cause <- context.Cause(ctx)
+		}, func(ctx context.Context, pipe <-chan int, cancel func(error)) {
+			drain(pipe)
+		}, WithWorkers(2))
+	})
+
+	var pe *PanicError
+	assert.ErrorAs(<-cause, &pe)
+	assert.Equal("boom", pe.Value)
+}
+
+func TestForEachCtx(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var sum int64
+	ForEachCtx(func(ctx context.Context, source chan<- int64) {
+		for i := int64(1); i <= 100; i++ {
+			source <- i
+		}
+	}, func(ctx context.Context, item int64) {
+		assert.Nil(ctx.Err())
+		atomic.AddInt64(&sum, item)
+	})
+	assert.Equal(int64(5050), sum)
+}
+
+func TestForEachCtxParentCancelled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	parent, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var cancelled int32
+	ForEachCtx(func(ctx context.Context, source chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case source <- i:
+			}
+		}
+	}, func(ctx context.Context, item int) {
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+	}, WithContext(parent), WithWorkers(2))
+	assert.Equal(int32(1), atomic.LoadInt32(&cancelled))
+}
+
+func TestFinishCtx(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	err := FinishCtx(context.Background(), func(ctx context.Context) error {
+		return errDummy
+	}, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(err, errDummy)
+	assert.Nil(FinishCtx(context.Background()))
+}
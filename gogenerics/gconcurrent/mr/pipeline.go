@@ -0,0 +1,240 @@
+package mr
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/miniLCT/gosb/gogenerics/gconstraints"
+)
+
+type (
+	// Pipeline is a typed multi-stage streaming processing, built with NewPipeline and AddStage,
+	// and run by one of the sinks: Collect, Stream or Reduce.
+	//
+	// All stages of a run share one pipeline context: a cancel(err) in any stage, a panic
+	// in any generator, mapper or reducer, or the parent ctx being done cancels all of them,
+	// and every stage drains its input so that nothing blocks.
+	//
+	// A Pipeline only describes the stages, it can be run many times.
+	Pipeline[T any] struct {
+		start func(ps *pipelineState) <-chan T
+	}
+
+	// pipelineState is the state shared by all stages of a pipeline run.
+	pipelineState struct {
+		parent    context.Context
+		ctx       context.Context
+		cancel    context.CancelCauseFunc
+		panicChan *onceChan
+		err       gconstraints.AtomicError
+		errs      errorCollector
+		fail      func(error)
+		// stops stop the progress reports of the stages, they're added when the
+		// pipeline is started, all in the goroutine of the sink.
+		stops []func()
+	}
+)
+
+// NewPipeline returns a Pipeline whose source elements are sent by generate.
+func NewPipeline[T any](generate GenerateCtxFunc[T]) *Pipeline[T] {
+	return &Pipeline[T]{
+		start: func(ps *pipelineState) <-chan T {
+			return buildSource(ps.ctx, generate, ps.panicChan)
+		},
+	}
+}
+
+// PipelineFromSlice returns a Pipeline whose source elements are items.
+func PipelineFromSlice[T any](items []T) *Pipeline[T] {
+	return NewPipeline(func(ctx context.Context, source chan<- T) {
+		for _, item := range items {
+			select {
+			case <-ctx.Done():
+				return
+			case source <- item:
+			}
+		}
+	})
+}
+
+// AddStage returns a Pipeline that maps the output of p with mapper.
+// opts customize the stage, such as WithWorkers, WithBuffer, WithRetry or WithCollectErrors,
+// WithContext is ignored since the pipeline context comes from the sink.
+func AddStage[T, U any](p *Pipeline[T], mapper MapperCtxFunc[T, U], opts ...Option) *Pipeline[U] {
+	return &Pipeline[U]{
+		start: func(ps *pipelineState) <-chan U {
+			return runStage(ps, p.start(ps), mapper, buildOptions(opts...))
+		},
+	}
+}
+
+// Collect runs the pipeline and returns all the output elements, the order is not kept.
+// A panic in any stage is re-panicked in the calling goroutine.
+func (p *Pipeline[T]) Collect(ctx context.Context, opts ...Option) ([]T, error) {
+	return Reduce(ctx, p, func(_ context.Context, pipe <-chan T, writer Writer[[]T], cancel func(error)) {
+		var res []T
+		for v := range pipe {
+			res = append(res, v)
+		}
+		writer.Write(res)
+	}, opts...)
+}
+
+// Stream runs the pipeline and returns the channel of output elements, and a wait func
+// that returns the error of the run once the channel is closed.
+// The caller must read the channel until it's closed, or cancel ctx.
+// A panic in any stage closes the channel, and is re-panicked in wait.
+func (p *Pipeline[T]) Stream(ctx context.Context) (<-chan T, func() error) {
+	ps := newPipelineState(ctx)
+	out := p.start(ps)
+	res := make(chan T)
+	done := make(chan struct{})
+	var panicked any
+
+	go func() {
+		defer func() {
+			ps.cancel(nil)
+			ps.stop()
+			close(res)
+			close(done)
+		}()
+
+		for {
+			select {
+			case v := <-ps.panicChan.channel:
+				panicked = v
+				ps.cancel(&PanicError{Value: v})
+				drain(out)
+				return
+			case v, ok := <-out:
+				if !ok {
+					return
+				}
+
+				select {
+				case <-ps.ctx.Done():
+					drain(out)
+					return
+				case res <- v:
+				}
+			}
+		}
+	}()
+
+	return res, func() error {
+		<-done
+		if panicked != nil {
+			panic(panicked)
+		}
+
+		return ps.result(nil)
+	}
+}
+
+// Reduce runs the pipeline p and reduces its output elements with given reducer.
+// opts customize the reducing, the reducer's cancel cancels the whole pipeline.
+// A panic in any stage is re-panicked in the calling goroutine.
+func Reduce[T, V any](ctx context.Context, p *Pipeline[T], reducer ReducerCtxFunc[T, V], opts ...Option) (V, error) {
+	ps := newPipelineState(ctx)
+	defer ps.stop()
+	defer ps.cancel(nil)
+
+	options := buildOptions(opts...)
+	options.ctx = ctx
+	val, err := mapReduceWithPanicChan(ps.ctx, ps.cancel, p.start(ps), ps.panicChan,
+		func(_ context.Context, item T, writer Writer[T], _ func(error)) {
+			writer.Write(item)
+		}, reducer, options)
+	if err = ps.result(err); err != nil {
+		// with WithCollectErrors stages, the value reduced from the successful items is kept
+		if _, ok := err.(*AggregateError); ok {
+			return val, err
+		}
+
+		var zero V
+		return zero, err
+	}
+
+	return val, nil
+}
+
+func newPipelineState(ctx context.Context) *pipelineState {
+	ps := &pipelineState{
+		parent:    ctx,
+		panicChan: &onceChan{channel: make(chan any)},
+	}
+	ps.ctx, ps.cancel = context.WithCancelCause(ctx)
+	ps.fail = once(func(err error) {
+		if err == nil {
+			err = ErrCancelWithNil
+		}
+
+		ps.err.Set(err)
+		ps.cancel(err)
+	})
+
+	return ps
+}
+
+// stop stops the progress reports of all stages, and waits for their last reports.
+func (ps *pipelineState) stop() {
+	for _, stop := range ps.stops {
+		stop()
+	}
+}
+
+// result returns the error of the run, stage errors come first, then the parent ctx,
+// then err of the sink, and the collected errors at last.
+func (ps *pipelineState) result(err error) error {
+	if e := ps.err.Load(); e != nil {
+		return e
+	}
+	if e := ps.parent.Err(); e != nil {
+		return e
+	}
+	if err != nil {
+		return err
+	}
+
+	return ps.errs.err()
+}
+
+func runStage[T, U any](ps *pipelineState, source <-chan T, mapper MapperCtxFunc[T, U],
+	options *mapReduceOptions) <-chan U {
+	al := newAdaptiveLimit(options)
+	mapper = retryMapper(adaptiveMapper(mapper, al), options)
+	pending := new(atomic.Int64)
+	hs := newHookStats(options.hooks, func() int {
+		return len(source) + int(pending.Load())
+	})
+	mapper = hookMapper(mapper, hs)
+	// a stage has no end of its own, the last report is made when the pipeline context is done
+	ps.stops = append(ps.stops, hs.report(ps.ctx))
+	collector := make(chan U, options.bufferSize())
+
+	go executeMappers(mapperContext[T, U]{
+		ctx: ps.ctx,
+		mapper: func(item T, w Writer[U]) {
+			if !options.collectErrors {
+				mapper(ps.ctx, item, w, ps.fail)
+				return
+			}
+
+			mapper(ps.ctx, item, w, func(err error) {
+				ps.errs.add(item, err)
+			})
+		},
+		source:    source,
+		panicChan: ps.panicChan,
+		collector: collector,
+		doneChan:  ps.ctx.Done(),
+		workers:   options.workers,
+		limiter:   options.limiter,
+		weight:    options.weight,
+		priority:  options.priority,
+		adaptive:  al,
+		pending:   pending,
+	})
+
+	return collector
+}
@@ -0,0 +1,173 @@
+package mr
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPipeline(n int) *Pipeline[string] {
+	in := make([]int, n)
+	for i := range in {
+		in[i] = i
+	}
+
+	double := AddStage(PipelineFromSlice(in), func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		writer.Write(item * 2)
+	}, WithWorkers(4), WithBuffer(8))
+	return AddStage(double, func(ctx context.Context, item int, writer Writer[string], cancel func(error)) {
+		writer.Write(strconv.Itoa(item))
+	}, WithWorkers(2))
+}
+
+func TestPipelineCollect(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := newTestPipeline(100)
+	// a pipeline can run many times
+	for i := 0; i < 2; i++ {
+		out, err := p.Collect(context.Background())
+		assert.Nil(err)
+		assert.Len(out, 100)
+		sort.Slice(out, func(i, j int) bool {
+			a, _ := strconv.Atoi(out[i])
+			b, _ := strconv.Atoi(out[j])
+			return a < b
+		})
+		assert.Equal("198", out[99])
+	}
+}
+
+func TestPipelineReduce(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	sum, err := Reduce(context.Background(), newTestPipeline(10),
+		func(ctx context.Context, pipe <-chan string, writer Writer[int], cancel func(error)) {
+			var sum int
+			for v := range pipe {
+				n, _ := strconv.Atoi(v)
+				sum += n
+			}
+			writer.Write(sum)
+		})
+	assert.Nil(err)
+	assert.Equal(90, sum)
+}
+
+func TestPipelineStream(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	out, wait := newTestPipeline(50).Stream(context.Background())
+	var n int
+	for range out {
+		n++
+	}
+	assert.Nil(wait())
+	assert.Equal(50, n)
+}
+
+func TestPipelineCancel(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := AddStage(NewPipeline(func(ctx context.Context, source chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case source <- i:
+			}
+		}
+	}), func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		if item == 10 {
+			cancel(errDummy)
+		}
+		writer.Write(item)
+	})
+	p = AddStage(p, func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		writer.Write(item)
+	})
+
+	out, err := p.Collect(context.Background())
+	assert.ErrorIs(err, errDummy)
+	assert.Nil(out)
+}
+
+func TestPipelineParentCancelled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p := AddStage(NewPipeline(func(ctx context.Context, source chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case source <- i:
+			}
+		}
+	}), func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		writer.Write(item)
+	})
+
+	out, wait := p.Stream(ctx)
+	for range out {
+	}
+	assert.ErrorIs(wait(), context.DeadlineExceeded)
+}
+
+func TestPipelinePanic(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := AddStage(PipelineFromSlice([]int{1, 2, 3}), func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		if item == 2 {
+			panic("boom")
+		}
+		writer.Write(item)
+	})
+	p = AddStage(p, func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		writer.Write(item)
+	})
+
+	assert.PanicsWithValue("boom", func() {
+		_, _ = p.Collect(context.Background())
+	})
+
+	out, wait := p.Stream(context.Background())
+	for range out {
+	}
+	assert.PanicsWithValue("boom", func() {
+		_ = wait()
+	})
+}
+
+func TestPipelineCollectErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := AddStage(PipelineFromSlice([]int{1, 2, 3, 4}), func(ctx context.Context, item int, writer Writer[int], cancel func(error)) {
+		if item%2 == 0 {
+			cancel(errDummy)
+			return
+		}
+		writer.Write(item)
+	}, WithCollectErrors())
+
+	out, err := p.Collect(context.Background())
+	assert.ErrorIs(err, errDummy)
+	sort.Ints(out)
+	assert.Equal([]int{1, 3}, out)
+
+	var ae *AggregateError
+	assert.ErrorAs(err, &ae)
+	assert.Len(ae.Errors, 2)
+}
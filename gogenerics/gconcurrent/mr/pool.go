@@ -0,0 +1,265 @@
+package mr
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/miniLCT/gosb/gogenerics/gconstraints"
+)
+
+var (
+	// ErrPoolClosed is an error that a task is submitted to a pool that has been shut down.
+	ErrPoolClosed = errors.New("pool closed")
+
+	// ErrPoolFull is an error that a task is rejected because the pool queue is full.
+	ErrPoolFull = errors.New("pool queue full")
+)
+
+type (
+	// Pool is a bounded worker pool that can be shared by many callers, such as request handlers
+	// that each fan out small jobs. It's created with NewPool, and must be shut down with Shutdown.
+	Pool struct {
+		tasks   chan *Task
+		reject  bool
+		quit    chan struct{}
+		mu      sync.Mutex
+		closed  bool
+		submits sync.WaitGroup
+		workers sync.WaitGroup
+	}
+
+	// Task is the handle of a task submitted to a Pool.
+	Task struct {
+		ctx      context.Context
+		fn       func(ctx context.Context) error
+		onDone   func(t *Task)
+		done     chan struct{}
+		err      error
+		panicked any
+	}
+
+	// Group runs tasks on a Pool, and waits for all of them.
+	// The group context is cancelled on the first error, like Finish.
+	Group struct {
+		pool      *Pool
+		ctx       context.Context
+		cancel    context.CancelCauseFunc
+		wg        sync.WaitGroup
+		err       gconstraints.AtomicError
+		fail      func(error)
+		panicOnce sync.Once
+		panicked  any
+	}
+)
+
+// WithRejectWhenFull customizes a Pool to reject tasks with ErrPoolFull when its queue is full,
+// instead of blocking the submitter.
+func WithRejectWhenFull() Option {
+	return func(opts *mapReduceOptions) {
+		opts.rejectWhenFull = true
+	}
+}
+
+// NewPool returns a Pool with given options, WithWorkers sets the number of worker goroutines,
+// WithBuffer sets the queue size, and WithRejectWhenFull sets the reject policy.
+func NewPool(opts ...Option) *Pool {
+	options := buildOptions(opts...)
+	p := &Pool{
+		tasks:  make(chan *Task, options.bufferSize()),
+		reject: options.rejectWhenFull,
+		quit:   make(chan struct{}),
+	}
+
+	p.workers.Add(options.workers)
+	for i := 0; i < options.workers; i++ {
+		go func() {
+			defer p.workers.Done()
+			for t := range p.tasks {
+				t.run()
+			}
+		}()
+	}
+
+	return p
+}
+
+// Submit queues fn to run with ctx on the pool, and returns the handle of the task.
+// If ctx is done before the task starts, fn is not called and the task fails with ctx.Err().
+// A panic in fn is captured as a *PanicError.
+//
+// If the queue is full, Submit blocks until there's room or ctx is done,
+// or returns ErrPoolFull with WithRejectWhenFull.
+func (p *Pool) Submit(ctx context.Context, fn func(ctx context.Context) error) (*Task, error) {
+	return p.submit(ctx, fn, nil)
+}
+
+// Group returns a new Group on the pool, whose tasks get a ctx derived from ctx.
+func (p *Pool) Group(ctx context.Context) *Group {
+	g := &Group{pool: p}
+	g.ctx, g.cancel = context.WithCancelCause(ctx)
+	g.fail = once(func(err error) {
+		g.err.Set(err)
+		g.cancel(err)
+	})
+
+	return g
+}
+
+// Finish runs fns on the pool, cancelled on any error.
+func (p *Pool) Finish(fns ...func() error) error {
+	if len(fns) == 0 {
+		return nil
+	}
+
+	g := p.Group(context.Background())
+	for _, fn := range fns {
+		fn := fn
+		g.Go(func(ctx context.Context) error {
+			return fn()
+		})
+	}
+
+	return g.Wait()
+}
+
+// Shutdown stops the pool from accepting tasks, and waits until all queued and running tasks finished,
+// or ctx is done. Queued tasks still run after Shutdown returned with ctx.Err().
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.quit)
+		// no submitter is able to send after quit is closed and the in-flight ones returned
+		go func() {
+			p.submits.Wait()
+			close(p.tasks)
+		}()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+func (p *Pool) submit(ctx context.Context, fn func(ctx context.Context) error, onDone func(t *Task)) (*Task, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	p.submits.Add(1)
+	p.mu.Unlock()
+	defer p.submits.Done()
+
+	t := &Task{
+		ctx:    ctx,
+		fn:     fn,
+		onDone: onDone,
+		done:   make(chan struct{}),
+	}
+	if p.reject {
+		select {
+		case p.tasks <- t:
+			return t, nil
+		default:
+			return nil, ErrPoolFull
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.quit:
+		return nil, ErrPoolClosed
+	case p.tasks <- t:
+		return t, nil
+	}
+}
+
+// Done returns a channel that's closed when the task finished.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns the error of the task, it's only valid after Done is closed.
+func (t *Task) Err() error {
+	return t.err
+}
+
+// Wait waits for the task to finish and returns its error, or returns ctx.Err() if ctx is done first.
+func (t *Task) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.done:
+		return t.err
+	}
+}
+
+func (t *Task) run() {
+	defer func() {
+		if t.onDone != nil {
+			t.onDone(t)
+		}
+		close(t.done)
+	}()
+
+	if err := t.ctx.Err(); err != nil {
+		t.err = err
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.panicked = r
+			t.err = &PanicError{Value: r}
+		}
+	}()
+
+	t.err = t.fn(t.ctx)
+}
+
+// Go runs fn on the pool. If the task can't be submitted, the group fails with the submit error.
+// Don't call Go from a task of a pool that blocks when full, it may deadlock once all workers do so.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	_, err := g.pool.submit(g.ctx, fn, func(t *Task) {
+		defer g.wg.Done()
+
+		if t.panicked != nil {
+			g.panicOnce.Do(func() {
+				g.panicked = t.panicked
+			})
+			g.cancel(t.err)
+		} else if t.err != nil {
+			g.fail(t.err)
+		}
+	})
+	if err != nil {
+		g.fail(err)
+		g.wg.Done()
+	}
+}
+
+// Wait waits for all the tasks of the group, and returns the first error.
+// A panic in any task is re-panicked in the calling goroutine.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+	if g.panicked != nil {
+		panic(g.panicked)
+	}
+
+	return g.err.Load()
+}
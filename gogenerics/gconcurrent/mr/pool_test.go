@@ -0,0 +1,183 @@
+package mr
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolSubmit(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(2))
+	defer p.Shutdown(context.Background())
+
+	var sum int64
+	tasks := make([]*Task, 0, 10)
+	for i := 1; i <= 10; i++ {
+		i := i
+		task, err := p.Submit(context.Background(), func(ctx context.Context) error {
+			atomic.AddInt64(&sum, int64(i))
+			return nil
+		})
+		assert.Nil(err)
+		tasks = append(tasks, task)
+	}
+	for _, task := range tasks {
+		assert.Nil(task.Wait(context.Background()))
+	}
+	assert.Equal(int64(55), atomic.LoadInt64(&sum))
+
+	task, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		return errDummy
+	})
+	assert.Nil(err)
+	<-task.Done()
+	assert.ErrorIs(task.Err(), errDummy)
+}
+
+func TestPoolPanic(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(1))
+	defer p.Shutdown(context.Background())
+
+	task, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		panic("boom")
+	})
+	assert.Nil(err)
+	var pe *PanicError
+	assert.ErrorAs(task.Wait(context.Background()), &pe)
+	assert.Equal("boom", pe.Value)
+
+	// the worker survives the panic
+	task, err = p.Submit(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	assert.Nil(err)
+	assert.Nil(task.Wait(context.Background()))
+}
+
+func TestPoolReject(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(1), WithBuffer(1), WithRejectWhenFull())
+	defer p.Shutdown(context.Background())
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	assert.Nil(err)
+	<-started
+	_, err = p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.Nil(err)
+	_, err = p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.ErrorIs(err, ErrPoolFull)
+	close(release)
+}
+
+func TestPoolBlock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(1), WithBuffer(0))
+	defer p.Shutdown(context.Background())
+
+	release := make(chan struct{})
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	assert.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Submit(ctx, func(ctx context.Context) error { return nil })
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	close(release)
+}
+
+func TestPoolShutdown(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(1), WithBuffer(4))
+	var ran int32
+	for i := 0; i < 4; i++ {
+		_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+		assert.Nil(err)
+	}
+
+	assert.Nil(p.Shutdown(context.Background()))
+	// queued tasks are run before shutdown returns
+	assert.Equal(int32(4), atomic.LoadInt32(&ran))
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.ErrorIs(err, ErrPoolClosed)
+	assert.Nil(p.Shutdown(context.Background()))
+}
+
+func TestPoolShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(1))
+	release := make(chan struct{})
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	assert.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(p.Shutdown(ctx), context.DeadlineExceeded)
+	close(release)
+	assert.Nil(p.Shutdown(context.Background()))
+}
+
+func TestPoolGroup(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := NewPool(WithWorkers(4))
+	defer p.Shutdown(context.Background())
+
+	g := p.Group(context.Background())
+	var sum int64
+	for i := 1; i <= 4; i++ {
+		i := i
+		g.Go(func(ctx context.Context) error {
+			atomic.AddInt64(&sum, int64(i))
+			return nil
+		})
+	}
+	assert.Nil(g.Wait())
+	assert.Equal(int64(10), atomic.LoadInt64(&sum))
+
+	assert.ErrorIs(p.Finish(func() error {
+		return errDummy
+	}, func() error {
+		return nil
+	}), errDummy)
+	assert.Nil(p.Finish())
+
+	assert.PanicsWithValue("boom", func() {
+		_ = p.Finish(func() error {
+			panic("boom")
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package mr
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/miniLCT/gosb/gogenerics/gcontainers/gheap"
+)
+
+const defaultPriorityWindow = 1024
+
+type (
+	priorityOptions struct {
+		priority func(item any) int
+		aging    time.Duration
+		window   int
+	}
+
+	prioritizedItem[T any] struct {
+		item T
+		key  int64
+		seq  uint64
+	}
+)
+
+// WithPriority customizes a mapreduce processing to map the items with higher priority(item) first
+// when the workers are busy, items of the same priority are mapped in arrival order.
+// Up to the priority window items are read ahead from the source to be ordered, see WithPriorityWindow.
+//
+// If aging > 0, the priority of a waiting item goes up by 1 every aging, so that
+// low priority items are not starved. T must be the element type of the source,
+// otherwise items have priority 0.
+func WithPriority[T any](priority func(item T) int, aging time.Duration) Option {
+	return func(opts *mapReduceOptions) {
+		opts.priority.priority = func(item any) int {
+			if v, ok := unwrapItem(item).(T); ok {
+				return priority(v)
+			}
+
+			return 0
+		}
+		opts.priority.aging = aging
+	}
+}
+
+// WithPriorityWindow customizes the number of items read ahead to be ordered by WithPriority,
+// it defaults to 1024.
+func WithPriorityWindow(size int) Option {
+	return func(opts *mapReduceOptions) {
+		opts.priority.window = size
+	}
+}
+
+// prioritize returns a channel that receives the items from source, the highest priority first
+// among the read ahead ones. The returned channel is closed once source is closed and all its
+// items are taken, or when done is closed, source is drained then.
+// pending, if not nil, is incremented for every item taken from source.
+func prioritize[T any](source <-chan T, done <-chan struct{}, po priorityOptions, pending *atomic.Int64) <-chan T {
+	window := po.window
+	if window < 1 {
+		window = defaultPriorityWindow
+	}
+
+	start := time.Now()
+	less := func(a, b prioritizedItem[T]) bool {
+		// the heap pops the minimum, so the larger key goes first
+		if a.key != b.key {
+			return a.key > b.key
+		}
+		return a.seq < b.seq
+	}
+	h := gheap.New(less)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer drain(source)
+
+		var (
+			// the items in h, top is not counted
+			size   int
+			seq    uint64
+			top    prioritizedItem[T]
+			hasTop bool
+		)
+		in := source
+		for in != nil || hasTop || size > 0 {
+			if !hasTop && size > 0 {
+				top, hasTop = gheap.Pop(h), true
+				size--
+			}
+			var send chan<- T
+			if hasTop {
+				send = out
+			}
+			// stop reading ahead once the window is full
+			recv := in
+			if size+1 >= window && hasTop {
+				recv = nil
+			}
+
+			select {
+			case <-done:
+				return
+			case item, ok := <-recv:
+				if !ok {
+					in = nil
+					continue
+				}
+
+				if pending != nil {
+					pending.Add(1)
+				}
+				seq++
+				pi := prioritizedItem[T]{
+					item: item,
+					key:  po.key(item, time.Since(start)),
+					seq:  seq,
+				}
+				if hasTop && less(pi, top) {
+					pi, top = top, pi
+				}
+				if hasTop {
+					gheap.Push(h, pi)
+					size++
+				} else {
+					top, hasTop = pi, true
+				}
+			case send <- top.item:
+				var zero prioritizedItem[T]
+				top, hasTop = zero, false
+			}
+		}
+	}()
+
+	return out
+}
+
+// key orders the items, an item waited for a longer time has a greater key with aging:
+// p1 + (now-t1)/aging > p2 + (now-t2)/aging equals p1*aging - t1 > p2*aging - t2.
+func (po priorityOptions) key(item any, arrival time.Duration) int64 {
+	priority := int64(po.priority(item))
+	if po.aging <= 0 {
+		return priority
+	}
+
+	return priority*int64(po.aging) - int64(arrival)
+}
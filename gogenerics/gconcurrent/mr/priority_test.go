@@ -0,0 +1,90 @@
+package mr
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPriority(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	started := make(chan struct{})
+	sent := make(chan struct{})
+	var (
+		mu    sync.Mutex
+		order []int
+	)
+	ForEach(func(source chan<- int) {
+		// keep the only worker busy, the others wait in the heap
+		source <- 0
+		<-started
+		for _, item := range []int{1, 5, 3, 5, 2} {
+			source <- item
+		}
+		close(sent)
+	}, func(item int) {
+		if item == 0 {
+			close(started)
+			<-sent
+			return
+		}
+
+		mu.Lock()
+		order = append(order, item)
+		mu.Unlock()
+	}, WithWorkers(1), WithPriority(func(item int) int {
+		return item
+	}, 0))
+	assert.Equal([]int{5, 5, 3, 2, 1}, order)
+}
+
+func TestPriorityAging(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	po := priorityOptions{
+		priority: func(item any) int {
+			return item.(int)
+		},
+		aging: time.Millisecond,
+	}
+	// a low priority item waited 10ms goes before a high priority item just arrived
+	assert.Greater(po.key(0, 0), po.key(5, 10*time.Millisecond))
+	assert.Less(po.key(0, 0), po.key(5, 2*time.Millisecond))
+
+	po.aging = 0
+	assert.Less(po.key(0, 0), po.key(5, time.Hour))
+}
+
+func TestPrioritizeWindow(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	source := make(chan int)
+	out := prioritize(source, nil, priorityOptions{
+		priority: func(item any) int {
+			return item.(int)
+		},
+		window: 2,
+	}, nil)
+	source <- 0
+	source <- 1
+	// the window is full, source is not read
+	select {
+	case source <- 2:
+		assert.Fail("read beyond the window")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	assert.Equal(1, <-out)
+	source <- 2
+	close(source)
+	assert.Equal(2, <-out)
+	assert.Equal(0, <-out)
+	_, ok := <-out
+	assert.False(ok)
+}
@@ -0,0 +1,147 @@
+package mr
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrExceedsBurst is an error that more tokens are asked than the limiter's burst.
+var ErrExceedsBurst = errors.New("limiter: n exceeds burst")
+
+// Limiter is a token bucket rate limiter, it's safe for concurrent use.
+// The bucket refills at rate tokens per second, and holds at most burst tokens.
+//
+// A Limiter can be shared by many mapreduce processings with WithRateLimit,
+// or used on its own.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter that allows rate events per second, with bursts of at most burst events.
+// A rate <= 0 means no limit. burst is at least 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow reports whether an event may happen now, and takes a token if so.
+func (l *Limiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, and takes n tokens if so.
+// It's always true if n <= 0, no token is taken then.
+func (l *Limiter) AllowN(n int) bool {
+	if n <= 0 || l.unlimited() {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(time.Now())
+	if l.tokens < float64(n) {
+		return false
+	}
+
+	l.tokens -= float64(n)
+	return true
+}
+
+// Wait blocks until an event may happen, or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen, or ctx is done.
+// The tokens are given back if ctx is done before that.
+// It returns nil at once if n <= 0, no token is taken then.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if l.unlimited() {
+		return ctx.Err()
+	}
+	if n > l.burst {
+		return ErrExceedsBurst
+	}
+
+	l.mu.Lock()
+	l.advance(time.Now())
+	// take the tokens first, a negative balance is the reservation of this waiter
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	if sleep(ctx, wait) {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.tokens += float64(n)
+	l.mu.Unlock()
+	return ctx.Err()
+}
+
+func (l *Limiter) unlimited() bool {
+	return l.rate <= 0 || math.IsInf(l.rate, 1)
+}
+
+// advance refills the bucket up to now, l.mu must be held.
+func (l *Limiter) advance(now time.Time) {
+	elapsed := now.Sub(l.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	l.last = now
+	l.tokens += elapsed.Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+}
+
+// WithRateLimit customizes a mapreduce processing to start mappers no faster than limiter allows,
+// every item takes one token.
+func WithRateLimit(limiter *Limiter) Option {
+	return func(opts *mapReduceOptions) {
+		opts.limiter = limiter
+	}
+}
+
+// WithWeight customizes a mapreduce processing to let an item take fn(item) worker slots,
+// so that fewer heavy items run at the same time. The weight is clamped to [1, workers].
+// T must be the element type of the source, otherwise items have weight 1.
+func WithWeight[T any](fn func(item T) int) Option {
+	return func(opts *mapReduceOptions) {
+		opts.weight = func(item any) int {
+			if v, ok := unwrapItem(item).(T); ok {
+				return fn(v)
+			}
+
+			return 1
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package mr
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	l := NewLimiter(1, 3)
+	assert.True(l.Allow())
+	assert.True(l.AllowN(2))
+	assert.False(l.Allow())
+
+	unlimited := NewLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		assert.True(unlimited.Allow())
+	}
+}
+
+func TestLimiterNonPositiveN(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// n <= 0 neither takes nor credits tokens
+	l := NewLimiter(0.001, 3)
+	assert.True(l.AllowN(3))
+	assert.True(l.AllowN(0))
+	assert.True(l.AllowN(-5))
+	assert.False(l.Allow())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Nil(l.WaitN(ctx, 0))
+	assert.Nil(l.WaitN(ctx, -5))
+	assert.False(l.Allow())
+}
+
+func TestLimiterWait(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	l := NewLimiter(100, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.Nil(l.Wait(context.Background()))
+	}
+	assert.True(time.Since(start) >= 35*time.Millisecond)
+	assert.ErrorIs(l.WaitN(context.Background(), 2), ErrExceedsBurst)
+}
+
+func TestLimiterWaitCancelled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	l := NewLimiter(0.1, 1)
+	assert.True(l.Allow())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(l.Wait(ctx), context.DeadlineExceeded)
+
+	// the reservation is given back
+	l.mu.Lock()
+	assert.True(l.tokens > -0.5)
+	l.mu.Unlock()
+}
+
+func TestMapRateLimit(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	start := time.Now()
+	out, err := Map(context.Background(), []int{1, 2, 3, 4, 5, 6}, func(ctx context.Context, item int) (int, error) {
+		return item, nil
+	}, WithRateLimit(NewLimiter(100, 1)))
+	assert.Nil(err)
+	assert.Equal([]int{1, 2, 3, 4, 5, 6}, out)
+	assert.True(time.Since(start) >= 45*time.Millisecond)
+}
+
+func TestMapWeight(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	const workers = 4
+	var running, peak int32
+	in := []int{1, 4, 2, 2, 1, 3, 8, 1, 1, 1}
+	_, err := Map(context.Background(), in, func(ctx context.Context, item int) (int, error) {
+		w := item
+		if w > workers {
+			w = workers
+		}
+		cur := atomic.AddInt32(&running, int32(w))
+		for {
+			old := atomic.LoadInt32(&peak)
+			if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -int32(w))
+		return item, nil
+	}, WithWorkers(workers), WithWeight(func(item int) int {
+		return item
+	}))
+	assert.Nil(err)
+	assert.True(atomic.LoadInt32(&peak) <= workers)
+}
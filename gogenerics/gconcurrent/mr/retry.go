@@ -0,0 +1,194 @@
+package mr
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miniLCT/gosb/hack/fastrand"
+)
+
+const (
+	defaultBackoffBase = 50 * time.Millisecond
+	defaultBackoffMax  = 5 * time.Second
+)
+
+type (
+	retryOptions struct {
+		retries     int
+		backoffBase time.Duration
+		backoffMax  time.Duration
+		retryIf     func(err error) bool
+		itemTimeout time.Duration
+	}
+
+	// attempt records the cancel call of a single mapper attempt.
+	attempt struct {
+		mu     sync.Mutex
+		called bool
+		err    error
+	}
+
+	// bufferedWriter holds the output of a mapper attempt until it succeeded,
+	// so that a retried item doesn't write twice.
+	bufferedWriter[T any] struct {
+		mu   sync.Mutex
+		vals []T
+	}
+)
+
+// WithItemTimeout customizes a mapreduce processing to give every item attempt a ctx
+// derived from the pipeline context with the given timeout.
+// Only ctx-aware mappers and ForEachCtx mappers can observe it.
+func WithItemTimeout(timeout time.Duration) Option {
+	return func(opts *mapReduceOptions) {
+		opts.itemTimeout = timeout
+	}
+}
+
+// WithRetry customizes a mapreduce processing to run a mapper again, up to times extra attempts,
+// when it calls cancel with a non-nil error. Only the output of the successful attempt is written.
+// Once retries are exhausted, the last error goes to the normal cancel or error-collection path.
+func WithRetry(times int) Option {
+	return func(opts *mapReduceOptions) {
+		if times < 0 {
+			times = 0
+		}
+		opts.retries = times
+	}
+}
+
+// WithBackoff customizes the delay between retries, it doubles from base up to limit,
+// with a random jitter of up to half of the delay.
+func WithBackoff(base, limit time.Duration) Option {
+	return func(opts *mapReduceOptions) {
+		opts.backoffBase = base
+		opts.backoffMax = limit
+	}
+}
+
+// WithRetryIf customizes which errors are retried, by default all non-nil errors are.
+func WithRetryIf(fn func(err error) bool) Option {
+	return func(opts *mapReduceOptions) {
+		opts.retryIf = fn
+	}
+}
+
+// retryMapper wraps mapper with the per-item timeout and retry options,
+// it returns mapper itself if none of them is set.
+func retryMapper[T, U any](mapper MapperCtxFunc[T, U], options *mapReduceOptions) MapperCtxFunc[T, U] {
+	if options.retries == 0 && options.itemTimeout <= 0 {
+		return mapper
+	}
+
+	return func(ctx context.Context, item T, writer Writer[U], cancel func(error)) {
+		for i := 0; ; i++ {
+			var at attempt
+			buf := new(bufferedWriter[U])
+			func() {
+				actx, acancel := options.itemContext(ctx)
+				defer acancel()
+				mapper(actx, item, buf, at.cancel)
+			}()
+
+			called, err := at.result()
+			if !called {
+				buf.flush(writer)
+				return
+			}
+			if !options.shouldRetry(ctx, i, err) || !sleep(ctx, options.backoff(i)) {
+				cancel(err)
+				return
+			}
+		}
+	}
+}
+
+func (ro *retryOptions) itemContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ro.itemTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, ro.itemTimeout)
+}
+
+func (ro *retryOptions) shouldRetry(ctx context.Context, attempts int, err error) bool {
+	// cancel(nil) asks to stop, and there's no point retrying in a cancelled pipeline
+	if err == nil || attempts >= ro.retries || ctx.Err() != nil {
+		return false
+	}
+
+	return ro.retryIf == nil || ro.retryIf(err)
+}
+
+// backoff returns the delay before the retry after the given attempts.
+func (ro *retryOptions) backoff(attempts int) time.Duration {
+	base, limit := ro.backoffBase, ro.backoffMax
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	if limit <= 0 {
+		limit = defaultBackoffMax
+	}
+
+	delay := base
+	for i := 0; i < attempts && delay < limit; i++ {
+		delay <<= 1
+	}
+	if delay > limit {
+		delay = limit
+	}
+
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+
+	return time.Duration(half + fastrand.Int63n(half+1))
+}
+
+// sleep waits for d, it returns false if ctx is done before that.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (a *attempt) cancel(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.called {
+		a.called = true
+		a.err = err
+	}
+}
+
+func (a *attempt) result() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.called, a.err
+}
+
+func (bw *bufferedWriter[T]) Write(v T) {
+	bw.mu.Lock()
+	bw.vals = append(bw.vals, v)
+	bw.mu.Unlock()
+}
+
+func (bw *bufferedWriter[T]) flush(writer Writer[T]) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	for _, v := range bw.vals {
+		writer.Write(v)
+	}
+	bw.vals = nil
+}
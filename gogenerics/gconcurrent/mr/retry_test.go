@@ -0,0 +1,103 @@
+package mr
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errFatal = errors.New("fatal")
+
+func TestMapReduceRetry(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var calls int32
+	val, err := MapReduce(func(source chan<- int) {
+		source <- 1
+	}, func(item int, writer Writer[int], cancel func(error)) {
+		writer.Write(item)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			cancel(errDummy)
+		}
+	}, func(pipe <-chan int, writer Writer[int], cancel func(error)) {
+		var sum int
+		for v := range pipe {
+			sum += v
+		}
+		writer.Write(sum)
+	}, WithRetry(3), WithBackoff(time.Millisecond, 2*time.Millisecond))
+	assert.Nil(err)
+	// failed attempts don't write
+	assert.Equal(1, val)
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestMapReduceRetryExhausted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var calls int32
+	err := MapReduceVoid(func(source chan<- int) {
+		source <- 1
+	}, func(item int, writer Writer[int], cancel func(error)) {
+		atomic.AddInt32(&calls, 1)
+		cancel(errDummy)
+	}, func(pipe <-chan int, cancel func(error)) {
+		drain(pipe)
+	}, WithRetry(2), WithBackoff(time.Millisecond, time.Millisecond))
+	assert.ErrorIs(err, errDummy)
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestMapRetryIf(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var calls int32
+	_, err := Map(context.Background(), []int{1, 2}, func(ctx context.Context, item int) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		if item == 1 {
+			return 0, errFatal
+		}
+		return item, nil
+	}, WithRetry(5), WithBackoff(time.Millisecond, time.Millisecond), WithRetryIf(func(err error) bool {
+		return !errors.Is(err, errFatal)
+	}), WithCollectErrors())
+	assert.ErrorIs(err, errFatal)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMapItemTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var calls int32
+	out, err := Map(context.Background(), []int{1}, func(ctx context.Context, item int) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return item, nil
+	}, WithItemTimeout(10*time.Millisecond), WithRetry(1), WithBackoff(time.Millisecond, time.Millisecond))
+	assert.Nil(err)
+	assert.Equal([]int{1}, out)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ro := retryOptions{backoffBase: 10 * time.Millisecond, backoffMax: 50 * time.Millisecond}
+	for i := 0; i < 10; i++ {
+		d := ro.backoff(i)
+		assert.True(d <= 50*time.Millisecond)
+		assert.True(d >= 5*time.Millisecond)
+	}
+	assert.True(ro.backoff(2) >= 20*time.Millisecond)
+}
@@ -0,0 +1,215 @@
+package mr
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/miniLCT/gosb/gogenerics/gconcurrent/mapx"
+	"github.com/miniLCT/gosb/gogenerics/gconstraints"
+)
+
+type (
+	// KeyedMapperFunc is used to do element processing and emit key/value pairs,
+	// use cancel func to cancel the processing.
+	KeyedMapperFunc[T any, K comparable, V any] func(ctx context.Context, item T, emit func(key K, value V),
+		cancel func(error))
+
+	// CombinerFunc merges two values of the same key, it must be associative.
+	CombinerFunc[K comparable, V any] func(key K, a, b V) V
+
+	// KeyedReducerFunc is used to reduce all the values of a key,
+	// use cancel func to cancel the processing.
+	KeyedReducerFunc[K comparable, V, R any] func(ctx context.Context, key K, values []V, cancel func(error)) R
+)
+
+// WithReducers customizes a keyed mapreduce processing with given reducer goroutines,
+// it defaults to GOMAXPROCS.
+func WithReducers(reducers int) Option {
+	return func(opts *mapReduceOptions) {
+		if reducers < minWorkers {
+			reducers = minWorkers
+		}
+		opts.reducers = reducers
+	}
+}
+
+// MapReduceByKey maps all elements generated from given generate func into key/value pairs,
+// shuffles the pairs by key hash to parallel reducers, and returns the reduced value of every key.
+//
+// If combiner is not nil, the values of a key are merged on the mapper side for every item,
+// and on arrival on the reducer side, so that reducer gets a single value per key.
+func MapReduceByKey[T any, K comparable, V, R any](generate GenerateCtxFunc[T], mapper KeyedMapperFunc[T, K, V],
+	combiner CombinerFunc[K, V], reducer KeyedReducerFunc[K, V, R], opts ...Option) (map[K]R, error) {
+	var mu sync.Mutex
+	res := make(map[K]R)
+	err := shuffle(generate, mapper, combiner, reducer, func(_ context.Context, key K, r R) {
+		mu.Lock()
+		res[key] = r
+		mu.Unlock()
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// MapReduceByKeyStream is like MapReduceByKey, but streams the per-key results as soon as
+// a reducer finished them. The wait func returns the error once the channel is closed.
+// The caller must read the channel until it's closed, or cancel the ctx given by WithContext.
+// A panic is re-panicked in wait.
+func MapReduceByKeyStream[T any, K comparable, V, R any](generate GenerateCtxFunc[T],
+	mapper KeyedMapperFunc[T, K, V], combiner CombinerFunc[K, V], reducer KeyedReducerFunc[K, V, R],
+	opts ...Option) (<-chan gconstraints.Entry[K, R], func() error) {
+	out := make(chan gconstraints.Entry[K, R])
+	done := make(chan struct{})
+	var (
+		err      error
+		panicked any
+	)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = r
+			}
+			close(out)
+			close(done)
+		}()
+
+		err = shuffle(generate, mapper, combiner, reducer, func(ctx context.Context, key K, r R) {
+			select {
+			case <-ctx.Done():
+			case out <- gconstraints.Entry[K, R]{Key: key, Value: r}:
+			}
+		}, opts...)
+	}()
+
+	return out, func() error {
+		<-done
+		if panicked != nil {
+			panic(panicked)
+		}
+
+		return err
+	}
+}
+
+// shuffle runs the keyed mapreduce, and calls sink with every reduced key in the reducer goroutines.
+func shuffle[T any, K comparable, V, R any](generate GenerateCtxFunc[T], mapper KeyedMapperFunc[T, K, V],
+	combiner CombinerFunc[K, V], reducer KeyedReducerFunc[K, V, R], sink func(ctx context.Context, key K, r R),
+	opts ...Option) error {
+	options := buildOptions(opts...)
+	reducers := options.reducers
+	if reducers <= 0 {
+		reducers = runtime.GOMAXPROCS(0)
+	}
+	partitions := make([]chan gconstraints.Entry[K, V], reducers)
+	for i := range partitions {
+		partitions[i] = make(chan gconstraints.Entry[K, V], options.bufferSize())
+	}
+
+	// a cancelled mapreduce returns before the reducer finished, but sink must not be called after shuffle returned
+	reduced := make(chan struct{})
+	err := MapReduceVoidCtx(generate, func(ctx context.Context, item T, _ Writer[any], cancel func(error)) {
+		for _, pair := range emitPairs(ctx, item, mapper, combiner, cancel) {
+			select {
+			case <-ctx.Done():
+				return
+			case partitions[mapx.Hash(pair.Key)%uint64(reducers)] <- pair:
+			}
+		}
+	}, func(ctx context.Context, pipe <-chan any, cancel func(error)) {
+		defer close(reduced)
+
+		var (
+			wg        sync.WaitGroup
+			panicOnce sync.Once
+			panicked  any
+		)
+		for i := range partitions {
+			wg.Add(1)
+			go func(partition <-chan gconstraints.Entry[K, V]) {
+				defer func() {
+					if r := recover(); r != nil {
+						panicOnce.Do(func() {
+							panicked = r
+						})
+						drain(partition)
+					}
+					wg.Done()
+				}()
+
+				reducePartition(ctx, partition, combiner, reducer, sink, cancel)
+			}(partitions[i])
+		}
+
+		// pipe is closed once all mappers are done, no more pairs after that
+		drain(pipe)
+		for _, partition := range partitions {
+			close(partition)
+		}
+		wg.Wait()
+		if panicked != nil {
+			panic(panicked)
+		}
+	}, opts...)
+	<-reduced
+
+	return err
+}
+
+// emitPairs runs mapper on item, and returns the emitted pairs, combined by key if combiner is not nil.
+func emitPairs[T any, K comparable, V any](ctx context.Context, item T, mapper KeyedMapperFunc[T, K, V],
+	combiner CombinerFunc[K, V], cancel func(error)) []gconstraints.Entry[K, V] {
+	var (
+		mu    sync.Mutex
+		pairs []gconstraints.Entry[K, V]
+		index map[K]int
+	)
+	if combiner != nil {
+		index = make(map[K]int)
+	}
+
+	mapper(ctx, item, func(key K, value V) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if combiner != nil {
+			if i, ok := index[key]; ok {
+				pairs[i].Value = combiner(key, pairs[i].Value, value)
+				return
+			}
+			index[key] = len(pairs)
+		}
+		pairs = append(pairs, gconstraints.Entry[K, V]{Key: key, Value: value})
+	}, cancel)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return pairs
+}
+
+// reducePartition groups the pairs of a partition by key, and reduces every key once the partition is closed.
+func reducePartition[K comparable, V, R any](ctx context.Context, partition <-chan gconstraints.Entry[K, V],
+	combiner CombinerFunc[K, V], reducer KeyedReducerFunc[K, V, R], sink func(ctx context.Context, key K, r R),
+	cancel func(error)) {
+	groups := make(map[K][]V)
+	for pair := range partition {
+		values, ok := groups[pair.Key]
+		if ok && combiner != nil {
+			values[0] = combiner(pair.Key, values[0], pair.Value)
+			continue
+		}
+		groups[pair.Key] = append(values, pair.Value)
+	}
+
+	for key, values := range groups {
+		if ctx.Err() != nil {
+			return
+		}
+
+		sink(ctx, key, reducer(ctx, key, values, cancel))
+	}
+}
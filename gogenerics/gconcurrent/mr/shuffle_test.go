@@ -0,0 +1,153 @@
+package mr
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lines = []string{
+	"the quick brown fox",
+	"jumps over the lazy dog",
+	"the dog barks",
+}
+
+func generateLines(ctx context.Context, source chan<- string) {
+	for _, line := range lines {
+		source <- line
+	}
+}
+
+func mapWords(ctx context.Context, line string, emit func(string, int), cancel func(error)) {
+	for _, word := range strings.Fields(line) {
+		emit(word, 1)
+	}
+}
+
+func sumCounts(ctx context.Context, word string, counts []int, cancel func(error)) int {
+	var sum int
+	for _, c := range counts {
+		sum += c
+	}
+	return sum
+}
+
+func TestMapReduceByKey(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	counts, err := MapReduceByKey(generateLines, mapWords, nil, sumCounts, WithReducers(3))
+	assert.Nil(err)
+	assert.Equal(3, counts["the"])
+	assert.Equal(2, counts["dog"])
+	assert.Equal(1, counts["fox"])
+	assert.Len(counts, 9)
+}
+
+func TestMapReduceByKeyCompositeKey(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type point struct {
+		x, y float64
+	}
+	type node struct {
+		visits int
+	}
+	nodes := []*node{{}, {}}
+	negZero := math.Copysign(0, -1)
+	counts, err := MapReduceByKey(func(ctx context.Context, source chan<- int) {
+		for i := 0; i < 100; i++ {
+			source <- i
+		}
+	}, func(ctx context.Context, i int, emit func(point, int), cancel func(error)) {
+		// +0 and -0 are the same key
+		if i%2 == 0 {
+			emit(point{0, 1}, 1)
+		} else {
+			emit(point{negZero, 1}, 1)
+		}
+	}, nil, func(ctx context.Context, p point, counts []int, cancel func(error)) int {
+		return len(counts)
+	}, WithReducers(8))
+	assert.Nil(err)
+	assert.Equal(map[point]int{{0, 1}: 100}, counts)
+
+	visits, err := MapReduceByKey(func(ctx context.Context, source chan<- int) {
+		for i := 0; i < 100; i++ {
+			source <- i
+		}
+	}, func(ctx context.Context, i int, emit func(*node, int), cancel func(error)) {
+		emit(nodes[i%2], 1)
+	}, nil, func(ctx context.Context, n *node, counts []int, cancel func(error)) int {
+		// the reducers may write what the keys point to
+		n.visits = len(counts)
+		return len(counts)
+	}, WithReducers(8))
+	assert.Nil(err)
+	assert.Equal(map[*node]int{nodes[0]: 50, nodes[1]: 50}, visits)
+}
+
+func TestMapReduceByKeyCombiner(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var maxValues int32
+	counts, err := MapReduceByKey(generateLines, mapWords, func(word string, a, b int) int {
+		return a + b
+	}, func(ctx context.Context, word string, counts []int, cancel func(error)) int {
+		if n := int32(len(counts)); n > atomic.LoadInt32(&maxValues) {
+			atomic.StoreInt32(&maxValues, n)
+		}
+		return sumCounts(ctx, word, counts, cancel)
+	}, WithReducers(2))
+	assert.Nil(err)
+	assert.Equal(3, counts["the"])
+	assert.Equal(int32(1), atomic.LoadInt32(&maxValues))
+}
+
+func TestMapReduceByKeyCancel(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	counts, err := MapReduceByKey(generateLines, func(ctx context.Context, line string, emit func(string, int),
+		cancel func(error)) {
+		if strings.HasPrefix(line, "jumps") {
+			cancel(errDummy)
+			return
+		}
+		mapWords(ctx, line, emit, cancel)
+	}, nil, sumCounts)
+	assert.ErrorIs(err, errDummy)
+	assert.Nil(counts)
+}
+
+func TestMapReduceByKeyStream(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	out, wait := MapReduceByKeyStream(generateLines, mapWords, nil, sumCounts, WithReducers(4))
+	counts := make(map[string]int)
+	for entry := range out {
+		counts[entry.Key] = entry.Value
+	}
+	assert.Nil(wait())
+	assert.Equal(3, counts["the"])
+	assert.Len(counts, 9)
+}
+
+func TestMapReduceByKeyReducerPanic(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.PanicsWithValue("boom", func() {
+		_, _ = MapReduceByKey(generateLines, mapWords, nil, func(ctx context.Context, word string, counts []int,
+			cancel func(error)) int {
+			panic("boom")
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package mr
+
+import "context"
+
+type indexedItem[T any] struct {
+	index int
+	item  T
+}
+
+func (ii indexedItem[T]) unwrapItem() any {
+	return ii.item
+}
+
+// Map applies fn to every element of in parallelly, and returns the results in input order.
+// It stops on the first error, the ctx passed to fn is cancelled at that time.
+// With WithCollectErrors, it runs all elements and returns an *AggregateError
+// whose items are the failed elements.
+func Map[T, U any](ctx context.Context, in []T, fn func(ctx context.Context, item T) (U, error),
+	opts ...Option) ([]U, error) {
+	out := make([]U, len(in))
+	if err := forEachIndexed(ctx, in, func(ctx context.Context, index int, item T) error {
+		v, err := fn(ctx, item)
+		if err != nil {
+			return err
+		}
+
+		out[index] = v
+		return nil
+	}, opts...); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Filter calls fn on every element of in parallelly, and returns the elements
+// that fn reports true for, in input order.
+// It stops on the first error, the ctx passed to fn is cancelled at that time.
+func Filter[T any](ctx context.Context, in []T, fn func(ctx context.Context, item T) (bool, error),
+	opts ...Option) ([]T, error) {
+	keep, err := Map(ctx, in, fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]T, 0, len(in))
+	for i, ok := range keep {
+		if ok {
+			out = append(out, in[i])
+		}
+	}
+
+	return out, nil
+}
+
+// FlatMap applies fn to every element of in parallelly, and returns the concatenation
+// of the results in input order.
+// It stops on the first error, the ctx passed to fn is cancelled at that time.
+func FlatMap[T, U any](ctx context.Context, in []T, fn func(ctx context.Context, item T) ([]U, error),
+	opts ...Option) ([]U, error) {
+	parts, err := Map(ctx, in, fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+	for _, part := range parts {
+		size += len(part)
+	}
+	out := make([]U, 0, size)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+
+	return out, nil
+}
+
+// forEachIndexed runs fn on every element of in with the mapreduce workers,
+// fn receives the index of the element so that callers can keep the input order.
+func forEachIndexed[T any](ctx context.Context, in []T, fn func(ctx context.Context, index int, item T) error,
+	opts ...Option) error {
+	if len(in) == 0 {
+		return nil
+	}
+
+	// the explicit ctx always wins over WithContext in opts
+	opts = append(opts[:len(opts):len(opts)], WithContext(ctx))
+	return MapReduceVoidCtx(func(ctx context.Context, source chan<- indexedItem[T]) {
+		for i, item := range in {
+			select {
+			case <-ctx.Done():
+				return
+			case source <- indexedItem[T]{index: i, item: item}:
+			}
+		}
+	}, func(ctx context.Context, item indexedItem[T], writer Writer[any], cancel func(error)) {
+		if err := fn(ctx, item.index, item.item); err != nil {
+			cancel(err)
+		}
+	}, func(_ context.Context, pipe <-chan any, cancel func(error)) {
+		drain(pipe)
+	}, opts...)
+}
@@ -0,0 +1,110 @@
+package mr
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miniLCT/gosb/hack/fastrand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	in := make([]int, 100)
+	for i := range in {
+		in[i] = i
+	}
+	out, err := Map(context.Background(), in, func(ctx context.Context, item int) (string, error) {
+		time.Sleep(time.Duration(fastrand.Intn(100)) * time.Microsecond)
+		return strconv.Itoa(item), nil
+	}, WithWorkers(8))
+	assert.Nil(err)
+	assert.Len(out, len(in))
+	for i, v := range out {
+		assert.Equal(strconv.Itoa(i), v)
+	}
+
+	out, err = Map(context.Background(), nil, func(ctx context.Context, item int) (string, error) {
+		return "", nil
+	})
+	assert.Nil(err)
+	assert.Empty(out)
+}
+
+func TestMapError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	out, err := Map(context.Background(), []int{1, 2, 3, 4}, func(ctx context.Context, item int) (int, error) {
+		if item == 3 {
+			return 0, errDummy
+		}
+		return item, nil
+	})
+	assert.ErrorIs(err, errDummy)
+	assert.Nil(out)
+}
+
+func TestMapCtxDone(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	in := []int{1, 2, 3, 4}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	out, err := Map(ctx, in, func(ctx context.Context, item int) (int, error) {
+		<-ctx.Done()
+		return item, nil
+	})
+	assert.ErrorIs(err, context.Canceled)
+	assert.NotErrorIs(err, context.DeadlineExceeded)
+	assert.Nil(out)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	kept, err := Filter(ctx, in, func(ctx context.Context, item int) (bool, error) {
+		<-ctx.Done()
+		return true, nil
+	})
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Nil(kept)
+
+	causeCtx, causeCancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { causeCancel(errDummy) })
+	flat, err := FlatMap(causeCtx, in, func(ctx context.Context, item int) ([]int, error) {
+		<-ctx.Done()
+		return []int{item}, nil
+	})
+	assert.ErrorIs(err, errDummy)
+	assert.Nil(flat)
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	out, err := Filter(context.Background(), []int{1, 2, 3, 4, 5, 6}, func(ctx context.Context, item int) (bool, error) {
+		return item%2 == 0, nil
+	}, WithWorkers(3))
+	assert.Nil(err)
+	assert.Equal([]int{2, 4, 6}, out)
+}
+
+func TestFlatMap(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	out, err := FlatMap(context.Background(), []int{1, 2, 3}, func(ctx context.Context, item int) ([]int, error) {
+		res := make([]int, item)
+		for i := range res {
+			res[i] = item
+		}
+		return res, nil
+	})
+	assert.Nil(err)
+	assert.Equal([]int{1, 2, 2, 3, 3, 3}, out)
+}
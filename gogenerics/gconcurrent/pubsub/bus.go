@@ -0,0 +1,252 @@
+// Package pubsub provides a typed in-process publish/subscribe event bus.
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned by Publish after the bus is closed.
+var ErrClosed = errors.New("pubsub: bus closed")
+
+type (
+	// Bus fans out the events of type T to its subscribers.
+	//
+	// Every subscriber has its own buffered channel and overflow policy, so a slow
+	// subscriber never delays the others unless its policy is Block.
+	// The events of a publisher are received in the order they are published.
+	//
+	// A Bus must be created with New.
+	Bus[T any] struct {
+		opts    options
+		dropped atomic.Uint64
+
+		mu     sync.RWMutex
+		all    map[*subscriber[T]]struct{}
+		topics map[string]map[*subscriber[T]]struct{}
+		closed bool
+	}
+
+	subscriber[T any] struct {
+		topic  string
+		filter func(T) bool
+		opts   options
+		ch     chan T
+		// done is closed on unsubscribe, to wake up the blocked publishers.
+		done chan struct{}
+		once sync.Once
+
+		// mu serializes the deliveries, and guards closed.
+		mu     sync.Mutex
+		closed bool
+	}
+)
+
+// New returns a Bus, opts are the defaults of its subscribers.
+func New[T any](opts ...Option) *Bus[T] {
+	return &Bus[T]{
+		opts:   buildOptions(newOptions(), opts...),
+		all:    make(map[*subscriber[T]]struct{}),
+		topics: make(map[string]map[*subscriber[T]]struct{}),
+	}
+}
+
+// Subscribe subscribes to the events of all topics that filter accepts, a nil filter accepts all.
+// It returns the channel of the events, and unsubscribe which closes the channel.
+// opts override the options of the bus for this subscriber.
+func (b *Bus[T]) Subscribe(filter func(T) bool, opts ...Option) (<-chan T, func()) {
+	return b.subscribe("", filter, opts...)
+}
+
+// SubscribeTopic is like Subscribe, but only receives the events published to topic.
+func (b *Bus[T]) SubscribeTopic(topic string, filter func(T) bool, opts ...Option) (<-chan T, func()) {
+	return b.subscribe(topic, filter, opts...)
+}
+
+func (b *Bus[T]) subscribe(topic string, filter func(T) bool, opts ...Option) (<-chan T, func()) {
+	s := &subscriber[T]{
+		topic:  topic,
+		filter: filter,
+		opts:   buildOptions(b.opts, opts...),
+		done:   make(chan struct{}),
+	}
+	if s.opts.sync {
+		s.ch = make(chan T)
+	} else {
+		s.ch = make(chan T, s.opts.buffer)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.close()
+		return s.ch, func() {}
+	}
+	if topic == "" {
+		b.all[s] = struct{}{}
+	} else {
+		if b.topics[topic] == nil {
+			b.topics[topic] = make(map[*subscriber[T]]struct{})
+		}
+		b.topics[topic][s] = struct{}{}
+	}
+
+	return s.ch, func() {
+		b.unsubscribe(s)
+	}
+}
+
+func (b *Bus[T]) unsubscribe(s *subscriber[T]) {
+	b.mu.Lock()
+	if s.topic == "" {
+		delete(b.all, s)
+	} else if subs := b.topics[s.topic]; subs != nil {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(b.topics, s.topic)
+		}
+	}
+	b.mu.Unlock()
+
+	s.close()
+}
+
+// Publish publishes v to the subscribers of all topics.
+func (b *Bus[T]) Publish(v T) error {
+	return b.publish("", v)
+}
+
+// PublishTopic publishes v to the subscribers of topic, and the subscribers of all topics.
+func (b *Bus[T]) PublishTopic(topic string, v T) error {
+	return b.publish(topic, v)
+}
+
+func (b *Bus[T]) publish(topic string, v T) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	subs := make([]*subscriber[T], 0, len(b.all)+len(b.topics[topic]))
+	for s := range b.all {
+		subs = append(subs, s)
+	}
+	if topic != "" {
+		for s := range b.topics[topic] {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	// deliver out of the lock, so a blocked delivery doesn't block subscribing
+	for _, s := range subs {
+		if s.filter != nil && !s.filter(v) {
+			continue
+		}
+		if s.deliver(v) {
+			b.dropped.Add(1)
+		}
+	}
+
+	return nil
+}
+
+// Dropped returns the number of events dropped by the overflow policies.
+func (b *Bus[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close unsubscribes all the subscribers, Publish returns ErrClosed after it.
+func (b *Bus[T]) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subs := make([]*subscriber[T], 0, len(b.all))
+	for s := range b.all {
+		subs = append(subs, s)
+	}
+	for _, topicSubs := range b.topics {
+		for s := range topicSubs {
+			subs = append(subs, s)
+		}
+	}
+	b.all, b.topics = nil, nil
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.close()
+	}
+}
+
+// deliver sends v to the subscriber following its overflow policy,
+// and reports whether an event is dropped.
+func (s *subscriber[T]) deliver(v T) (dropped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	if s.opts.sync {
+		select {
+		case s.ch <- v:
+		case <-s.done:
+		}
+		return false
+	}
+
+	select {
+	case s.ch <- v:
+		return false
+	default:
+	}
+
+	switch s.opts.overflow {
+	case DropNewest:
+		return true
+	case Block:
+		var timeout <-chan time.Time
+		if s.opts.blockTimeout > 0 {
+			timer := time.NewTimer(s.opts.blockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case s.ch <- v:
+			return false
+		case <-s.done:
+			return false
+		case <-timeout:
+			return true
+		}
+	default:
+		// the publishers are serialized by mu, only the subscriber takes from the buffer concurrently
+		for {
+			select {
+			case s.ch <- v:
+				return dropped
+			default:
+			}
+			select {
+			case <-s.ch:
+				dropped = true
+			default:
+			}
+		}
+	}
+}
+
+func (s *subscriber[T]) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
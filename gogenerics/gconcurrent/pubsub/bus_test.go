@@ -0,0 +1,153 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drain[T any](ch <-chan T) []T {
+	var events []T
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, v)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	assert := assert.New(t)
+
+	b := New[int]()
+	all, unsubAll := b.Subscribe(nil)
+	even, _ := b.Subscribe(func(v int) bool { return v%2 == 0 })
+	orders, _ := b.SubscribeTopic("orders", nil)
+
+	for i := 1; i <= 4; i++ {
+		assert.Nil(b.Publish(i))
+	}
+	assert.Nil(b.PublishTopic("orders", 10))
+	assert.Nil(b.PublishTopic("users", 11))
+
+	assert.Equal([]int{1, 2, 3, 4, 10, 11}, drain(all))
+	assert.Equal([]int{2, 4, 10}, drain(even))
+	assert.Equal([]int{10}, drain(orders))
+
+	unsubAll()
+	unsubAll()
+	_, ok := <-all
+	assert.False(ok)
+	assert.Nil(b.Publish(5))
+
+	b.Close()
+	assert.ErrorIs(b.Publish(6), ErrClosed)
+	assert.Equal([]int(nil), drain(orders))
+	ch, unsub := b.Subscribe(nil)
+	_, ok = <-ch
+	assert.False(ok)
+	unsub()
+}
+
+func TestOverflow(t *testing.T) {
+	assert := assert.New(t)
+
+	b := New[int](WithBuffer(2))
+	oldest, _ := b.Subscribe(nil)
+	newest, _ := b.Subscribe(nil, WithOverflow(DropNewest))
+	blocked, _ := b.Subscribe(nil, WithBlockTimeout(10*time.Millisecond))
+
+	for i := 1; i <= 4; i++ {
+		assert.Nil(b.Publish(i))
+	}
+	assert.Equal([]int{3, 4}, drain(oldest))
+	assert.Equal([]int{1, 2}, drain(newest))
+	assert.Equal([]int{1, 2}, drain(blocked))
+	assert.Equal(uint64(6), b.Dropped())
+}
+
+func TestBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	b := New[int](WithBuffer(1), WithBlockTimeout(0))
+	ch, unsub := b.Subscribe(nil)
+	assert.Nil(b.Publish(1))
+
+	published := make(chan struct{})
+	go func() {
+		assert.Nil(b.Publish(2))
+		assert.Nil(b.Publish(3))
+		close(published)
+	}()
+	assert.Equal(1, <-ch)
+	assert.Equal(2, <-ch)
+	// the blocked publisher is released by unsubscribe
+	unsub()
+	<-published
+	assert.Equal(uint64(0), b.Dropped())
+}
+
+func TestSync(t *testing.T) {
+	assert := assert.New(t)
+
+	b := New[string](WithSync())
+	ch, unsub := b.Subscribe(nil)
+	var (
+		got []string
+		wg  sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := range ch {
+			got = append(got, v)
+		}
+	}()
+
+	assert.Nil(b.Publish("a"))
+	assert.Nil(b.Publish("b"))
+	unsub()
+	wg.Wait()
+	assert.Equal([]string{"a", "b"}, got)
+}
+
+func TestConcurrentPublish(t *testing.T) {
+	assert := assert.New(t)
+
+	const publishers, events = 4, 1000
+	b := New[[2]int](WithBuffer(16))
+	ch, unsub := b.Subscribe(nil)
+
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				assert.Nil(b.Publish([2]int{p, i}))
+			}
+		}(p)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// the events of a publisher keep their order, even with drops
+		last := [publishers]int{-1, -1, -1, -1}
+		for v := range ch {
+			assert.Less(last[v[0]], v[1])
+			last[v[0]] = v[1]
+		}
+	}()
+	wg.Wait()
+	unsub()
+	<-done
+	b.Close()
+}
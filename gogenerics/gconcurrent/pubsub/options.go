@@ -0,0 +1,80 @@
+package pubsub
+
+import "time"
+
+const defaultBufferSize = 64
+
+// OverflowPolicy tells Publish what to do when the buffer of a subscriber is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest drops the oldest event in the buffer to make room for the new one.
+	DropOldest OverflowPolicy = iota
+	// DropNewest drops the new event.
+	DropNewest
+	// Block waits for room until the block timeout, and drops the new event after it.
+	Block
+)
+
+type (
+	// Option customizes a Bus, or a subscriber when passed to Subscribe.
+	Option func(opts *options)
+
+	options struct {
+		buffer       int
+		overflow     OverflowPolicy
+		blockTimeout time.Duration
+		sync         bool
+	}
+)
+
+func buildOptions(base options, opts ...Option) options {
+	for _, opt := range opts {
+		opt(&base)
+	}
+
+	return base
+}
+
+func newOptions() options {
+	return options{
+		buffer:   defaultBufferSize,
+		overflow: DropOldest,
+	}
+}
+
+// WithBuffer customizes the buffer size of the subscribers, which defaults to 64.
+func WithBuffer(size int) Option {
+	return func(opts *options) {
+		if size < 1 {
+			opts.buffer = 1
+		} else {
+			opts.buffer = size
+		}
+	}
+}
+
+// WithOverflow customizes what to do when the buffer of a subscriber is full, it defaults to DropOldest.
+func WithOverflow(policy OverflowPolicy) Option {
+	return func(opts *options) {
+		opts.overflow = policy
+	}
+}
+
+// WithBlockTimeout sets the overflow policy to Block, waiting up to timeout for room.
+// It waits until the subscriber unsubscribes if timeout <= 0.
+func WithBlockTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.overflow = Block
+		opts.blockTimeout = timeout
+	}
+}
+
+// WithSync makes Publish hand every event to the subscribers directly, without buffering:
+// Publish returns after all the matching subscribers received the event, or unsubscribed.
+// It makes the delivery deterministic in tests.
+func WithSync() Option {
+	return func(opts *options) {
+		opts.sync = true
+	}
+}
@@ -0,0 +1,147 @@
+// Package singleflight provides a duplicate call suppression mechanism,
+// it's a generic version of golang.org/x/sync/singleflight with context-aware waiting.
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit is the error of a call whose fn called runtime.Goexit.
+var errGoexit = errors.New("singleflight: fn called runtime.Goexit")
+
+type (
+	// Group represents a class of work and forms a namespace in
+	// which units of work can be executed with duplicate suppression.
+	//
+	// The zero Group is ready for use. A Group must not be copied after first use.
+	Group[K comparable, V any] struct {
+		mu sync.Mutex
+		m  map[K]*call[V]
+	}
+
+	// Result holds the results of Do, so they can be passed on a channel.
+	Result[V any] struct {
+		Val    V
+		Err    error
+		Shared bool
+	}
+
+	// PanicError is the error of a call whose fn panicked, every caller of the call gets it.
+	PanicError struct {
+		Value any
+		Stack []byte
+	}
+
+	// call is an in-flight or completed Do call.
+	call[V any] struct {
+		done   chan struct{}
+		val    V
+		err    error
+		dups   int
+		shared bool
+		chans  []chan<- Result[V]
+	}
+)
+
+// Error implements the error interface.
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: panic: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Do executes and returns the results of fn, making sure that only one execution
+// is in-flight for a given key at a time. If a duplicate comes in, the duplicate
+// caller waits for the original to complete and receives the same results.
+// The return value shared reports whether v was given to multiple callers.
+//
+// fn runs in its own goroutine, so Do returns ctx.Err() as soon as ctx is done,
+// while fn keeps running for the other callers of the key.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func() (V, error)) (v V, err error, shared bool) {
+	c, dup := g.join(key, fn, nil)
+	select {
+	case <-c.done:
+		return c.val, c.err, c.shared
+	case <-ctx.Done():
+		return v, ctx.Err(), dup
+	}
+}
+
+// DoChan is like Do but returns a channel that will receive the
+// results when they are ready. The channel is never closed.
+func (g *Group[K, V]) DoChan(key K, fn func() (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	g.join(key, fn, ch)
+	return ch
+}
+
+// Forget tells the group to forget about a key. Future calls to Do for this key
+// will call fn rather than waiting for an earlier call to complete.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
+
+// join returns the in-flight call of key, or starts one with fn.
+// dup reports whether the call was in-flight, ch receives the results if it's not nil.
+func (g *Group[K, V]) join(key K, fn func() (V, error), ch chan<- Result[V]) (c *call[V], dup bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.m == nil {
+		g.m = make(map[K]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		if ch != nil {
+			c.chans = append(c.chans, ch)
+		}
+		return c, true
+	}
+
+	c = &call[V]{done: make(chan struct{})}
+	if ch != nil {
+		c.chans = append(c.chans, ch)
+	}
+	g.m[key] = c
+	go g.doCall(c, key, fn)
+
+	return c, false
+}
+
+// doCall runs fn, and hands its results to the callers of c.
+func (g *Group[K, V]) doCall(c *call[V], key K, fn func() (V, error)) {
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			// fn called runtime.Goexit
+			c.err = errGoexit
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		// the key may be forgotten and taken by another call
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		c.shared = c.dups > 0
+		close(c.done)
+		for _, ch := range c.chans {
+			ch <- Result[V]{Val: c.val, Err: c.err, Shared: c.shared}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+
+		c.val, c.err = fn()
+	}()
+	normalReturn = true
+}
@@ -0,0 +1,154 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	assert := assert.New(t)
+
+	var g Group[string, int]
+	v, err, shared := g.Do(context.Background(), "key", func() (int, error) {
+		return 1, nil
+	})
+	assert.Nil(err)
+	assert.Equal(1, v)
+	assert.False(shared)
+
+	errFn := errors.New("fn")
+	_, err, _ = g.Do(context.Background(), "key", func() (int, error) {
+		return 0, errFn
+	})
+	assert.ErrorIs(err, errFn)
+}
+
+func TestDoDupSuppress(t *testing.T) {
+	assert := assert.New(t)
+
+	const n = 10
+	var (
+		g       Group[string, int]
+		calls   int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	fn := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := g.Do(context.Background(), "key", fn)
+			assert.Nil(err)
+			assert.Equal(42, v)
+			assert.True(shared)
+		}()
+	}
+	// wait for all the callers to join
+	for {
+		g.mu.Lock()
+		c := g.m["key"]
+		joined := c != nil && c.dups == n-1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDoCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	var g Group[string, int]
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		<-release
+		return 1, nil
+	}
+
+	ch := g.DoChan("key", fn)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err, shared := g.Do(ctx, "key", fn)
+	assert.ErrorIs(err, context.Canceled)
+	assert.True(shared)
+
+	// the shared call goes on for the other callers
+	close(release)
+	res := <-ch
+	assert.Nil(res.Err)
+	assert.Equal(1, res.Val)
+	assert.True(res.Shared)
+}
+
+func TestDoPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	var g Group[string, int]
+	release := make(chan struct{})
+	ch := g.DoChan("key", func() (int, error) {
+		<-release
+		panic("boom")
+	})
+	ch2 := g.DoChan("key", func() (int, error) {
+		return 0, nil
+	})
+	close(release)
+
+	for _, c := range []<-chan Result[int]{ch, ch2} {
+		var pe *PanicError
+		assert.ErrorAs((<-c).Err, &pe)
+		assert.Equal("boom", pe.Value)
+		assert.NotEmpty(pe.Stack)
+	}
+
+	_, err, _ := g.Do(context.Background(), "goexit", func() (int, error) {
+		runtime.Goexit()
+		return 0, nil
+	})
+	assert.ErrorIs(err, errGoexit)
+}
+
+func TestForget(t *testing.T) {
+	assert := assert.New(t)
+
+	var g Group[string, int]
+	release := make(chan struct{})
+	ch := g.DoChan("key", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	g.Forget("key")
+
+	// a new call after Forget doesn't wait for the first one
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	v, err, shared := g.Do(ctx, "key", func() (int, error) {
+		return 2, nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, v)
+	assert.False(shared)
+
+	close(release)
+	assert.Equal(1, (<-ch).Val)
+	g.mu.Lock()
+	assert.Empty(g.m)
+	g.mu.Unlock()
+}
@@ -0,0 +1,389 @@
+package skipmap
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miniLCT/gosb/gogenerics/gconstraints"
+	"github.com/miniLCT/gosb/hack/fastrand"
+)
+
+const (
+	// maxLevel denotes the maximum height of the skiplist, it keeps the map
+	// efficient for up to 2^maxLevel keys.
+	maxLevel = 32
+)
+
+type (
+	// Map is a concurrent map sorted by keys, built on a lazy skiplist
+	// (Herlihy et al., "A Simple Optimistic Skiplist Algorithm").
+	//
+	// Load, First, Last and Range are lock-free, Store and Delete only lock
+	// the predecessors of the key on every level, so writes to different
+	// parts of the map don't contend.
+	//
+	// Range keeps walking the list while it's modified: keys deleted before
+	// they are reached are skipped, keys stored before they are reached are visited,
+	// and the keys are always visited in ascending order.
+	//
+	// The zero Map is empty and ready for use. A Map must not be copied after first use.
+	Map[K gconstraints.Ordered, V any] struct {
+		head   node[K, V]
+		once   sync.Once
+		level  atomic.Int64 // highest level in use
+		length atomic.Int64
+	}
+
+	node[K gconstraints.Ordered, V any] struct {
+		key   K
+		value atomic.Pointer[V]
+		next  []atomic.Pointer[node[K, V]]
+		mu    sync.Mutex
+		// marked is set when the node is logically deleted, before it's unlinked.
+		marked atomic.Bool
+		// fullyLinked is set when the node is linked on all its levels.
+		fullyLinked atomic.Bool
+	}
+)
+
+// New returns an empty Map.
+func New[K gconstraints.Ordered, V any]() *Map[K, V] {
+	return new(Map[K, V])
+}
+
+func (m *Map[K, V]) headNode() *node[K, V] {
+	// the head of the zero Map is made on first use
+	m.once.Do(func() {
+		m.head.next = make([]atomic.Pointer[node[K, V]], maxLevel)
+		m.head.fullyLinked.Store(true)
+	})
+
+	return &m.head
+}
+
+func newNode[K gconstraints.Ordered, V any](key K, value V, level int) *node[K, V] {
+	n := &node[K, V]{
+		key:  key,
+		next: make([]atomic.Pointer[node[K, V]], level),
+	}
+	n.value.Store(&value)
+	return n
+}
+
+func (n *node[K, V]) level() int {
+	return len(n.next)
+}
+
+func (n *node[K, V]) loadNext(i int) *node[K, V] {
+	return n.next[i].Load()
+}
+
+func (n *node[K, V]) loadValue() V {
+	return *n.value.Load()
+}
+
+// valid reports whether the node is present in the map.
+func (n *node[K, V]) valid() bool {
+	return n.fullyLinked.Load() && !n.marked.Load()
+}
+
+func randomLevel() int {
+	// each level is taken with probability 1/2
+	level := bits.TrailingZeros64(fastrand.Uint64()) + 1
+	if level > maxLevel {
+		level = maxLevel
+	}
+	return level
+}
+
+// find fills preds and succs with the nodes right before and from key on the lowest top levels,
+// and returns the node of key if it's linked on some of them.
+func (m *Map[K, V]) find(key K, preds, succs *[maxLevel]*node[K, V]) (found *node[K, V], top int) {
+	x := m.headNode()
+	top = int(m.level.Load())
+	for i := top - 1; i >= 0; i-- {
+		next := x.loadNext(i)
+		for next != nil && next.key < key {
+			x = next
+			next = x.loadNext(i)
+		}
+		preds[i] = x
+		succs[i] = next
+		if found == nil && next != nil && next.key == key {
+			found = next
+		}
+	}
+
+	return found, top
+}
+
+// findGreaterOrEqual returns the first node whose key is greater than or equal to key, or nil.
+func (m *Map[K, V]) findGreaterOrEqual(key K) *node[K, V] {
+	x := m.headNode()
+	for i := int(m.level.Load()) - 1; i >= 0; i-- {
+		next := x.loadNext(i)
+		for next != nil && next.key < key {
+			x = next
+			next = x.loadNext(i)
+		}
+		if next != nil && next.key == key {
+			return next
+		}
+	}
+
+	return x.loadNext(0)
+}
+
+// findLess returns the last node whose key is less than key, it's the head if there is none.
+func (m *Map[K, V]) findLess(key K) *node[K, V] {
+	x := m.headNode()
+	for i := int(m.level.Load()) - 1; i >= 0; i-- {
+		next := x.loadNext(i)
+		for next != nil && next.key < key {
+			x = next
+			next = x.loadNext(i)
+		}
+	}
+
+	return x
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no
+// value is present. The ok result indicates whether value was found in the map.
+func (m *Map[K, V]) Load(key K) (value V, ok bool) {
+	n := m.findGreaterOrEqual(key)
+	if n != nil && n.key == key && n.valid() {
+		return n.loadValue(), true
+	}
+
+	return value, false
+}
+
+// Store sets the value for a key.
+func (m *Map[K, V]) Store(key K, value V) {
+	m.store(key, value, true)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	return m.store(key, value, false)
+}
+
+func (m *Map[K, V]) store(key K, value V, overwrite bool) (actual V, loaded bool) {
+	var preds, succs [maxLevel]*node[K, V]
+	level := randomLevel()
+	// raise the highest level before linking the node, so that the searches
+	// after it's linked fill the predecessors on all its levels.
+	for {
+		highest := m.level.Load()
+		if int64(level) <= highest || m.level.CompareAndSwap(highest, int64(level)) {
+			break
+		}
+	}
+	for {
+		found, top := m.find(key, &preds, &succs)
+		if found != nil {
+			if found.marked.Load() {
+				// it's being deleted, try again after it's unlinked
+				continue
+			}
+			for !found.fullyLinked.Load() {
+				// it's being stored by another goroutine
+				runtime.Gosched()
+			}
+			if overwrite {
+				// store under the lock of the node, so that it's either before the node
+				// is marked and the value is returned by LoadAndDelete, or it's retried
+				found.mu.Lock()
+				if found.marked.Load() {
+					found.mu.Unlock()
+					continue
+				}
+				found.value.Store(&value)
+				found.mu.Unlock()
+				return value, true
+			}
+			return found.loadValue(), true
+		}
+
+		// the levels above the searched ones have the head as predecessor
+		for i := top; i < level; i++ {
+			preds[i] = m.headNode()
+			succs[i] = nil
+		}
+
+		// lock the predecessors from the bottom up and check nothing changed in between
+		var (
+			prev  *node[K, V]
+			valid = true
+			i     int
+		)
+		for i = 0; valid && i < level; i++ {
+			pred, succ := preds[i], succs[i]
+			if pred != prev {
+				pred.mu.Lock()
+				prev = pred
+			}
+			valid = !pred.marked.Load() && (succ == nil || !succ.marked.Load()) && pred.loadNext(i) == succ
+		}
+		if !valid {
+			unlockPreds(&preds, i)
+			continue
+		}
+
+		n := newNode(key, value, level)
+		for i := 0; i < level; i++ {
+			n.next[i].Store(succs[i])
+			preds[i].next[i].Store(n)
+		}
+		n.fullyLinked.Store(true)
+		unlockPreds(&preds, level)
+		m.length.Add(1)
+		return value, false
+	}
+}
+
+// unlockPreds unlocks the distinct predecessors of the lowest n levels.
+func unlockPreds[K gconstraints.Ordered, V any](preds *[maxLevel]*node[K, V], n int) {
+	var prev *node[K, V]
+	for i := 0; i < n; i++ {
+		if preds[i] != prev {
+			preds[i].mu.Unlock()
+			prev = preds[i]
+		}
+	}
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	var (
+		preds, succs [maxLevel]*node[K, V]
+		victim       *node[K, V]
+		marked       bool
+	)
+	for {
+		found, top := m.find(key, &preds, &succs)
+		if !marked {
+			if found == nil || !found.valid() {
+				return value, false
+			}
+			if found.level() > top {
+				// the search started before the highest level was raised for it
+				continue
+			}
+			victim = found
+			victim.mu.Lock()
+			if victim.marked.Load() {
+				// deleted by another goroutine
+				victim.mu.Unlock()
+				return value, false
+			}
+			victim.marked.Store(true)
+			marked = true
+		}
+
+		// lock the predecessors and unlink the victim on every level
+		level := victim.level()
+		if level > top {
+			continue
+		}
+		var (
+			prev  *node[K, V]
+			valid = true
+			i     int
+		)
+		for i = 0; valid && i < level; i++ {
+			pred := preds[i]
+			if pred != prev {
+				pred.mu.Lock()
+				prev = pred
+			}
+			valid = !pred.marked.Load() && pred.loadNext(i) == victim
+		}
+		if !valid {
+			unlockPreds(&preds, i)
+			continue
+		}
+
+		// unlink from the top down, so a reader on a lower level can still reach the successors
+		for i := level - 1; i >= 0; i-- {
+			preds[i].next[i].Store(victim.loadNext(i))
+		}
+		value = victim.loadValue()
+		victim.mu.Unlock()
+		unlockPreds(&preds, level)
+		m.length.Add(-1)
+		return value, true
+	}
+}
+
+// Delete deletes the value for a key.
+func (m *Map[K, V]) Delete(key K) {
+	m.LoadAndDelete(key)
+}
+
+// First returns the smallest key and its value, ok is false if the map is empty.
+func (m *Map[K, V]) First() (key K, value V, ok bool) {
+	for n := m.headNode().loadNext(0); n != nil; n = n.loadNext(0) {
+		if n.valid() {
+			return n.key, n.loadValue(), true
+		}
+	}
+
+	return key, value, false
+}
+
+// Last returns the largest key and its value, ok is false if the map is empty.
+func (m *Map[K, V]) Last() (key K, value V, ok bool) {
+	head := m.headNode()
+	x := head
+	for i := int(m.level.Load()) - 1; i >= 0; i-- {
+		for next := x.loadNext(i); next != nil; next = x.loadNext(i) {
+			x = next
+		}
+	}
+	// step back over the nodes being stored or deleted
+	for x != head && !x.valid() {
+		x = m.findLess(x.key)
+	}
+	if x == head {
+		return key, value, false
+	}
+
+	return x.key, x.loadValue(), true
+}
+
+// Range calls f sequentially in ascending order for each key in [from, to) and its value.
+// If f returns false, range stops the iteration.
+//
+// Range does not block other methods on the receiver, f may call any method on m.
+// Range does not correspond to any consistent snapshot of the Map's contents.
+func (m *Map[K, V]) Range(from, to K, f func(key K, value V) bool) {
+	for n := m.findGreaterOrEqual(from); n != nil && n.key < to; n = n.loadNext(0) {
+		if n.valid() && !f(n.key, n.loadValue()) {
+			return
+		}
+	}
+}
+
+// Ascend calls f sequentially in ascending order for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// Like Range, it does not correspond to any consistent snapshot of the Map's contents.
+func (m *Map[K, V]) Ascend(f func(key K, value V) bool) {
+	for n := m.headNode().loadNext(0); n != nil; n = n.loadNext(0) {
+		if n.valid() && !f(n.key, n.loadValue()) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (m *Map[K, V]) Len() int {
+	return int(m.length.Load())
+}
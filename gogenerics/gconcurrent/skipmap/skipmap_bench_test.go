@@ -0,0 +1,70 @@
+package skipmap
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miniLCT/gosb/gogenerics/gds/gskiplist"
+)
+
+// lockedSkipList is a gskiplist.SkipList guarded by a RWMutex, for comparison.
+type lockedSkipList struct {
+	mu sync.RWMutex
+	l  *gskiplist.SkipList[int, int]
+}
+
+func (l *lockedSkipList) Load(key int) (int, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.l.Find(key)
+}
+
+func (l *lockedSkipList) Store(key, value int) {
+	l.mu.Lock()
+	l.l.Insert(key, value)
+	l.mu.Unlock()
+}
+
+type orderedMap interface {
+	Load(int) (int, bool)
+	Store(key, value int)
+}
+
+func benchOrderedMap(b *testing.B, writes int) {
+	const mapSize = 1 << 12
+
+	for name, newMap := range map[string]func() orderedMap{
+		"skipmap":   func() orderedMap { return New[int, int]() },
+		"gskiplist": func() orderedMap { return &lockedSkipList{l: gskiplist.New[int, int]()} },
+	} {
+		b.Run(name, func(b *testing.B) {
+			m := newMap()
+			for i := 0; i < mapSize; i++ {
+				m.Store(i, i)
+			}
+			b.ResetTimer()
+
+			var id int64
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(atomic.AddInt64(&id, 1)) * b.N
+				for ; pb.Next(); i++ {
+					key := (i * 31) % mapSize
+					if i%10 < writes {
+						m.Store(key, i)
+					} else {
+						m.Load(key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkReadMostly(b *testing.B) {
+	benchOrderedMap(b, 1)
+}
+
+func BenchmarkWriteHeavy(b *testing.B) {
+	benchOrderedMap(b, 5)
+}
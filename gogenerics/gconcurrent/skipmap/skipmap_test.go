@@ -0,0 +1,228 @@
+package skipmap
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	assert := assert.New(t)
+
+	var m Map[int, string]
+	_, ok := m.Load(1)
+	assert.False(ok)
+	_, _, ok = m.First()
+	assert.False(ok)
+	_, _, ok = m.Last()
+	assert.False(ok)
+
+	for _, k := range []int{5, 3, 9, 1, 7} {
+		m.Store(k, "v")
+	}
+	m.Store(3, "three")
+	v, ok := m.Load(3)
+	assert.True(ok)
+	assert.Equal("three", v)
+	assert.Equal(5, m.Len())
+
+	v, loaded := m.LoadOrStore(3, "x")
+	assert.True(loaded)
+	assert.Equal("three", v)
+	v, loaded = m.LoadOrStore(4, "four")
+	assert.False(loaded)
+	assert.Equal("four", v)
+
+	k, _, ok := m.First()
+	assert.True(ok)
+	assert.Equal(1, k)
+	k, _, ok = m.Last()
+	assert.True(ok)
+	assert.Equal(9, k)
+
+	v, loaded = m.LoadAndDelete(4)
+	assert.True(loaded)
+	assert.Equal("four", v)
+	_, loaded = m.LoadAndDelete(4)
+	assert.False(loaded)
+	m.Delete(9)
+	m.Delete(1)
+	k, _, _ = m.First()
+	assert.Equal(3, k)
+	k, _, _ = m.Last()
+	assert.Equal(7, k)
+	assert.Equal(3, m.Len())
+}
+
+func TestMapRange(t *testing.T) {
+	assert := assert.New(t)
+
+	m := New[int, int]()
+	for i := 0; i < 100; i++ {
+		m.Store(i*2, i)
+	}
+
+	var keys []int
+	m.Range(10, 20, func(key, value int) bool {
+		assert.Equal(key/2, value)
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal([]int{10, 12, 14, 16, 18}, keys)
+
+	keys = keys[:0]
+	m.Range(11, 1000, func(key, value int) bool {
+		keys = append(keys, key)
+		return len(keys) < 3
+	})
+	assert.Equal([]int{12, 14, 16}, keys)
+
+	keys = keys[:0]
+	m.Ascend(func(key, value int) bool {
+		// f may call m
+		if key%4 == 0 {
+			m.Delete(key + 2)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	assert.Len(keys, 50)
+	assert.True(sort.IntsAreSorted(keys))
+	assert.Equal(50, m.Len())
+}
+
+func TestMapConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	const n = 1000
+	m := New[int, int]()
+	procs := runtime.GOMAXPROCS(0)
+	if procs < 4 {
+		procs = 4
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < procs; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				key := i*procs + g
+				m.Store(key, key)
+				if i%2 == 1 {
+					m.Delete(key)
+				}
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				prev := -1
+				m.Ascend(func(key, value int) bool {
+					assert.Less(prev, key)
+					assert.Equal(key, value)
+					prev = key
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(procs*n/2, m.Len())
+	var count int
+	m.Ascend(func(key, value int) bool {
+		assert.Equal(0, (key/procs)%2)
+		count++
+		return true
+	})
+	assert.Equal(procs*n/2, count)
+	k, _, _ := m.First()
+	assert.Equal(0, k)
+	k, _, _ = m.Last()
+	assert.Equal((n-2)*procs+procs-1, k)
+}
+
+func TestMapConcurrentSameKey(t *testing.T) {
+	assert := assert.New(t)
+
+	m := New[string, int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if i%3 == 0 {
+					m.Delete("k")
+				} else {
+					m.Store("k", g)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// at most one node per key
+	var count int
+	m.Ascend(func(key string, value int) bool {
+		count++
+		return true
+	})
+	assert.LessOrEqual(count, 1)
+	assert.Equal(count, m.Len())
+}
+
+func TestMapStoreLoadAndDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		keys = 4
+		n    = 20000
+	)
+	m := New[int, int]()
+	var (
+		wg sync.WaitGroup
+		// absent are the values not found right after they're stored,
+		// deleted are the values returned by LoadAndDelete
+		absent, deleted [keys]map[int]bool
+		mu              sync.Mutex
+	)
+	for k := 0; k < keys; k++ {
+		absent[k], deleted[k] = make(map[int]bool), make(map[int]bool)
+		wg.Add(3)
+		// a single writer per key, so a stored value is only replaced by the later ones
+		go func(k int) {
+			defer wg.Done()
+			for i := 1; i <= n; i++ {
+				m.Store(k, i)
+				if _, ok := m.Load(k); !ok {
+					absent[k][i] = true
+				}
+			}
+		}(k)
+		for d := 0; d < 2; d++ {
+			go func(k int) {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					if v, ok := m.LoadAndDelete(k); ok {
+						mu.Lock()
+						deleted[k][v] = true
+						mu.Unlock()
+					}
+				}
+			}(k)
+		}
+	}
+	wg.Wait()
+
+	// a value stored and gone with no later store must be returned by a LoadAndDelete
+	for k := 0; k < keys; k++ {
+		for v := range absent[k] {
+			assert.True(deleted[k][v], "key %d value %d is lost", k, v)
+		}
+	}
+}
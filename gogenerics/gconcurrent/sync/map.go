@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"encoding/json"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -64,6 +65,11 @@ type Map[K comparable, V any] struct {
 	// map, the dirty map will be promoted to the read map (in the unamended
 	// state) and the next store to the map will make a new dirty copy.
 	misses int
+
+	// count is the number of valid entries, it's shared by all the entries of the map
+	// and only replaced with mu held. Clear replaces it, so that the entries left
+	// behind don't count.
+	count atomic.Pointer[atomic.Int64]
 }
 
 // readOnly is an immutable struct stored atomically in the Map.read field.
@@ -100,14 +106,29 @@ type entry[V any] struct {
 	// only after first setting m.dirty[key] = e so that lookups using the dirty
 	// map find the entry.
 	p atomic.Pointer[V]
+
+	// count is the counter of the map that created the entry, it's updated
+	// whenever p changes between nil and a valid value.
+	count *atomic.Int64
 }
 
-func newEntry[V any](i V) *entry[V] {
-	e := &entry[V]{}
+func newEntry[V any](i V, count *atomic.Int64) *entry[V] {
+	e := &entry[V]{count: count}
 	e.p.Store(&i)
+	count.Add(1)
 	return e
 }
 
+// countLocked returns the counter of the map, it must be called with mu held.
+func (m *Map[K, V]) countLocked() *atomic.Int64 {
+	count := m.count.Load()
+	if count == nil {
+		count = new(atomic.Int64)
+		m.count.Store(count)
+	}
+	return count
+}
+
 func (m *Map[K, V]) loadReadOnly() readOnly[K, V] {
 	if p := m.read.Load(); p != nil {
 		return *p
@@ -197,7 +218,11 @@ func (e *entry[V]) unexpungeLocked() (wasExpunged bool) {
 //
 // The entry must be known not to be expunged.
 func (e *entry[V]) swapLocked(i *V) *V {
-	return e.p.Swap(i)
+	p := e.p.Swap(i)
+	if p == nil {
+		e.count.Add(1)
+	}
+	return p
 }
 
 // LoadOrStore returns the existing value for the key if present.
@@ -230,7 +255,7 @@ func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
 			m.dirtyLocked()
 			m.read.Store(&readOnly[K, V]{m: read.m, amended: true})
 		}
-		m.dirty[key] = newEntry(value)
+		m.dirty[key] = newEntry(value, m.countLocked())
 		actual, loaded = value, false
 	}
 	m.mu.Unlock()
@@ -259,6 +284,7 @@ func (e *entry[V]) tryLoadOrStore(i V) (actual V, loaded, ok bool) {
 	ic := i
 	for {
 		if e.p.CompareAndSwap(nil, &ic) {
+			e.count.Add(1)
 			return i, false, true
 		}
 		p = e.p.Load()
@@ -312,6 +338,7 @@ func (e *entry[V]) delete() (value V, ok bool) {
 			return zero, false
 		}
 		if e.p.CompareAndSwap(p, nil) {
+			e.count.Add(-1)
 			return *p, true
 		}
 	}
@@ -328,6 +355,9 @@ func (e *entry[V]) trySwap(i *V) (*V, bool) {
 			return nil, false
 		}
 		if e.p.CompareAndSwap(p, i) {
+			if p == nil {
+				e.count.Add(1)
+			}
 			return p, true
 		}
 	}
@@ -371,7 +401,7 @@ func (m *Map[K, V]) Swap(key K, value V) (previous V, loaded bool) {
 			m.dirtyLocked()
 			m.read.Store(&readOnly[K, V]{m: read.m, amended: true})
 		}
-		m.dirty[key] = newEntry(value)
+		m.dirty[key] = newEntry(value, m.countLocked())
 	}
 	m.mu.Unlock()
 	return previous, loaded
@@ -438,12 +468,148 @@ func (m *Map[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
 			return false
 		}
 		if e.p.CompareAndSwap(p, nil) {
+			e.count.Add(-1)
 			return true
 		}
 	}
 	return false
 }
 
+// ComputeOp tells Compute what to do with the computed value.
+type ComputeOp int
+
+const (
+	// UpdateOp stores the computed value.
+	UpdateOp ComputeOp = iota
+	// DeleteOp deletes the key.
+	DeleteOp
+	// CancelOp leaves the map unchanged.
+	CancelOp
+)
+
+// Compute calls fn with the current value of key, and stores, deletes or keeps
+// the value as fn tells, atomically: the result is applied only if the value
+// is still the one passed to fn, otherwise fn is called again with the new value.
+// So fn may be called more than once when racing with other writes of key, and it
+// may be called with the map locked, it must not call the methods of m.
+//
+// Compute returns the value of key after the call, and whether the key is present.
+// It's a write operation unless fn returns CancelOp.
+func (m *Map[K, V]) Compute(key K, fn func(old V, loaded bool) (newValue V, op ComputeOp)) (actual V, ok bool) {
+	read := m.loadReadOnly()
+	if e, found := read.m[key]; found {
+		if actual, ok, done := e.tryCompute(fn); done {
+			return actual, ok
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	read = m.loadReadOnly()
+	if e, found := read.m[key]; found {
+		if e.unexpungeLocked() {
+			m.dirty[key] = e
+		}
+		actual, ok, _ = e.tryCompute(fn)
+	} else if e, found := m.dirty[key]; found {
+		actual, ok, _ = e.tryCompute(fn)
+		m.missLocked()
+	} else {
+		var zero V
+		value, op := fn(zero, false)
+		if op != UpdateOp {
+			return zero, false
+		}
+		if !read.amended {
+			// We're adding the first new key to the dirty map.
+			// Make sure it is allocated and mark the read-only map as incomplete.
+			m.dirtyLocked()
+			m.read.Store(&readOnly[K, V]{m: read.m, amended: true})
+		}
+		m.dirty[key] = newEntry(value, m.countLocked())
+		actual, ok = value, true
+	}
+
+	return actual, ok
+}
+
+// tryCompute applies fn to the entry if the entry has not been expunged,
+// see Compute.
+//
+// If the entry is expunged, tryCompute leaves the entry unchanged and
+// returns with done==false.
+func (e *entry[V]) tryCompute(fn func(old V, loaded bool) (V, ComputeOp)) (actual V, ok, done bool) {
+	for {
+		p := e.p.Load()
+		if unsafe.Pointer(p) == expunged {
+			var zero V
+			return zero, false, false
+		}
+
+		var old V
+		if p != nil {
+			old = *p
+		}
+		value, op := fn(old, p != nil)
+		switch op {
+		case UpdateOp:
+			if e.p.CompareAndSwap(p, &value) {
+				if p == nil {
+					e.count.Add(1)
+				}
+				return value, true, true
+			}
+		case DeleteOp:
+			if p == nil {
+				var zero V
+				return zero, false, true
+			}
+			if e.p.CompareAndSwap(p, nil) {
+				e.count.Add(-1)
+				var zero V
+				return zero, false, true
+			}
+		default:
+			return old, p != nil, true
+		}
+	}
+}
+
+// LoadOrCompute returns the existing value for the key if present.
+// Otherwise, it stores and returns the value made by factory.
+// factory is called at most once, and only on a miss, but its value is dropped
+// if another write stores the key first. Like Compute, factory must not call the methods of m.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Map[K, V]) LoadOrCompute(key K, factory func() V) (actual V, loaded bool) {
+	var (
+		value    V
+		computed bool
+	)
+	actual, _ = m.Compute(key, func(old V, ok bool) (V, ComputeOp) {
+		loaded = ok
+		if ok {
+			return old, CancelOp
+		}
+		if !computed {
+			value, computed = factory(), true
+		}
+		return value, UpdateOp
+	})
+
+	return actual, loaded
+}
+
+// Update replaces the value of key with fn(old) atomically if key is present, see Compute.
+// It returns the new value, and whether the key is present.
+func (m *Map[K, V]) Update(key K, fn func(old V) V) (actual V, ok bool) {
+	return m.Compute(key, func(old V, loaded bool) (V, ComputeOp) {
+		if !loaded {
+			return old, CancelOp
+		}
+		return fn(old), UpdateOp
+	})
+}
+
 // Range calls f sequentially for each key and value present in the map.
 // If f returns false, range stops the iteration.
 //
@@ -488,6 +654,82 @@ func (m *Map[K, V]) Range(f func(key K, value V) bool) {
 	}
 }
 
+// Len returns the number of keys in the map in O(1).
+// It's exact when there are no concurrent writes, otherwise it's approximate:
+// writes in progress may or may not be counted.
+func (m *Map[K, V]) Len() int {
+	count := m.count.Load()
+	if count == nil {
+		return 0
+	}
+	if n := count.Load(); n > 0 {
+		return int(n)
+	}
+	return 0
+}
+
+// Clear deletes all the keys.
+// A write that runs concurrently with Clear may be lost, as if it happened before Clear.
+func (m *Map[K, V]) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	read := m.loadReadOnly()
+	if len(read.m) == 0 && !read.amended {
+		// Avoid allocating a new readOnly when the map is already clear.
+		return
+	}
+
+	m.read.Store(&readOnly[K, V]{})
+	m.dirty = nil
+	m.misses = 0
+	m.count.Store(new(atomic.Int64))
+}
+
+// Snapshot returns a copy of the map as a Go map.
+// Like Range, it doesn't necessarily correspond to any consistent snapshot of the Map's
+// contents under concurrent writes, but every key is copied at most once.
+func (m *Map[K, V]) Snapshot() map[K]V {
+	snapshot := make(map[K]V, m.Len())
+	m.Range(func(key K, value V) bool {
+		snapshot[key] = value
+		return true
+	})
+
+	return snapshot
+}
+
+// Keys returns the keys of the map in no particular order, see Snapshot.
+func (m *Map[K, V]) Keys() []K {
+	keys := make([]K, 0, m.Len())
+	m.Range(func(key K, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	return keys
+}
+
+// MarshalJSON implements json.Marshaler, the map is encoded as a JSON object of its Snapshot.
+func (m *Map[K, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Snapshot())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it replaces the content of the map
+// with the decoded JSON object.
+func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
+	var decoded map[K]V
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	m.Clear()
+	for k, v := range decoded {
+		m.Store(k, v)
+	}
+	return nil
+}
+
 func (m *Map[K, V]) missLocked() {
 	m.misses++
 	if m.misses < len(m.dirty) {
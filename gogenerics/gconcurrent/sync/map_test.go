@@ -1,8 +1,10 @@
 package sync
 
 import (
+	"encoding/json"
 	"reflect"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -155,3 +157,211 @@ func TestCompareAndSwap_NonExistingKey(t *testing.T) {
 		t.Fatalf("CompareAndSwap on an non-existing key succeeded")
 	}
 }
+
+func TestMapCompute(t *testing.T) {
+	m := &Map[string, int]{}
+	incr := func(old int, loaded bool) (int, ComputeOp) {
+		return old + 1, UpdateOp
+	}
+	if v, ok := m.Compute("a", incr); !ok || v != 1 {
+		t.Fatalf("Compute on a missing key got (%v, %v), want (1, true)", v, ok)
+	}
+	if v, ok := m.Compute("a", incr); !ok || v != 2 {
+		t.Fatalf("Compute on an existing key got (%v, %v), want (2, true)", v, ok)
+	}
+	if v, ok := m.Compute("a", func(old int, loaded bool) (int, ComputeOp) {
+		return 0, CancelOp
+	}); !ok || v != 2 {
+		t.Fatalf("cancelled Compute got (%v, %v), want (2, true)", v, ok)
+	}
+	if _, ok := m.Compute("a", func(old int, loaded bool) (int, ComputeOp) {
+		return 0, DeleteOp
+	}); ok {
+		t.Fatalf("Compute didn't delete the key")
+	}
+	if _, ok := m.Load("a"); ok {
+		t.Fatalf("Load found a deleted key")
+	}
+	if _, ok := m.Compute("b", func(old int, loaded bool) (int, ComputeOp) {
+		if loaded {
+			t.Fatalf("Compute loaded a missing key")
+		}
+		return 0, DeleteOp
+	}); ok {
+		t.Fatalf("Compute stored a deleted key")
+	}
+
+	if _, ok := m.Update("b", func(old int) int { return old + 1 }); ok {
+		t.Fatalf("Update stored a missing key")
+	}
+	m.Store("b", 1)
+	if v, ok := m.Update("b", func(old int) int { return old + 1 }); !ok || v != 2 {
+		t.Fatalf("Update got (%v, %v), want (2, true)", v, ok)
+	}
+}
+
+func TestMapLoadOrCompute(t *testing.T) {
+	m := &Map[string, int]{}
+	var calls int32
+	factory := func() int {
+		atomic.AddInt32(&calls, 1)
+		return 42
+	}
+
+	if v, loaded := m.LoadOrCompute("a", factory); loaded || v != 42 {
+		t.Fatalf("LoadOrCompute on a missing key got (%v, %v), want (42, false)", v, loaded)
+	}
+	if v, loaded := m.LoadOrCompute("a", factory); !loaded || v != 42 {
+		t.Fatalf("LoadOrCompute on an existing key got (%v, %v), want (42, true)", v, loaded)
+	}
+	if calls != 1 {
+		t.Fatalf("factory called %v times, want 1", calls)
+	}
+}
+
+func TestConcurrentCompute(t *testing.T) {
+	const keys, incrs = 8, 1000
+
+	m := &Map[int, int]{}
+	var wg sync.WaitGroup
+	procs := runtime.GOMAXPROCS(0)
+	for g := 0; g < procs; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < incrs; i++ {
+				m.Compute(i%keys, func(old int, loaded bool) (int, ComputeOp) {
+					return old + 1, UpdateOp
+				})
+				// churn the other keys to promote and rebuild the dirty map
+				m.Store(keys+g*incrs+i, i)
+				m.Delete(keys + g*incrs + i)
+				if i%100 == 0 {
+					m.Range(func(key, value int) bool { return true })
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var total int
+	for k := 0; k < keys; k++ {
+		v, _ := m.Load(k)
+		total += v
+	}
+	if total != procs*incrs {
+		t.Fatalf("Compute lost updates: got %v, want %v", total, procs*incrs)
+	}
+}
+
+func TestMapLen(t *testing.T) {
+	m := &Map[int, int]{}
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len of an empty Map got %v, want 0", n)
+	}
+
+	m.Store(1, 1)
+	m.Store(1, 2)
+	m.LoadOrStore(2, 2)
+	m.LoadOrStore(2, 3)
+	m.Swap(3, 3)
+	m.Compute(4, func(old int, loaded bool) (int, ComputeOp) { return 4, UpdateOp })
+	if n := m.Len(); n != 4 {
+		t.Fatalf("Len got %v, want 4", n)
+	}
+
+	// promote the dirty map, then delete and store again through the read map
+	m.Range(func(key, value int) bool { return true })
+	m.Delete(1)
+	m.LoadAndDelete(2)
+	m.CompareAndDelete(3, 3)
+	m.Compute(4, func(old int, loaded bool) (int, ComputeOp) { return 0, DeleteOp })
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len after deleting all keys got %v, want 0", n)
+	}
+	m.Store(1, 1)
+	m.LoadOrStore(2, 2)
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len got %v, want 2", n)
+	}
+
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len after Clear got %v, want 0", n)
+	}
+	if _, ok := m.Load(1); ok {
+		t.Fatalf("Load found a cleared key")
+	}
+	m.Store(1, 1)
+	if n := m.Len(); n != 1 {
+		t.Fatalf("Len after Clear and Store got %v, want 1", n)
+	}
+}
+
+func TestConcurrentLen(t *testing.T) {
+	const mapSize = 1 << 8
+
+	m := &Map[int, int]{}
+	var wg sync.WaitGroup
+	for g := runtime.GOMAXPROCS(0); g > 0; g-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1<<12; i++ {
+				key := int(fastrand.Int63n(mapSize))
+				switch i % 5 {
+				case 0:
+					m.Store(key, i)
+				case 1:
+					m.Delete(key)
+				case 2:
+					m.LoadOrStore(key, i)
+				case 3:
+					m.Swap(key, i)
+				default:
+					m.Range(func(key, value int) bool { return true })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, keys := m.Len(), len(m.Keys()); n != keys {
+		t.Fatalf("Len got %v, want %v", n, keys)
+	}
+}
+
+func TestMapSnapshotAndJSON(t *testing.T) {
+	m := &Map[string, int]{}
+	m.Store("a", 1)
+	m.Store("b", 2)
+
+	if snapshot := m.Snapshot(); !reflect.DeepEqual(snapshot, map[string]int{"a": 1, "b": 2}) {
+		t.Fatalf("Snapshot got %v", snapshot)
+	}
+	keys := m.Keys()
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("Keys got %v", keys)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":1,"b":2}` {
+		t.Fatalf("MarshalJSON got %s", data)
+	}
+
+	decoded := &Map[string, int]{}
+	decoded.Store("c", 3)
+	if err = json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot := decoded.Snapshot(); !reflect.DeepEqual(snapshot, map[string]int{"a": 1, "b": 2}) {
+		t.Fatalf("UnmarshalJSON got %v", snapshot)
+	}
+	if n := decoded.Len(); n != 2 {
+		t.Fatalf("Len after UnmarshalJSON got %v, want 2", n)
+	}
+}
@@ -0,0 +1,280 @@
+package sync
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlWheelSize is the number of slots of the timing wheel of TTLMap,
+// a slot is swept once per ttlWheelSize ticks.
+const ttlWheelSize = 512
+
+type (
+	// TTLMap is a Map whose entries expire after their time to live.
+	//
+	// An expired entry is never returned: it's deleted lazily when it's loaded,
+	// or by the background sweeper, whichever comes first. The sweeper is a timing
+	// wheel that checks only the entries due in the current tick, so its cost
+	// doesn't grow with the size of the map.
+	//
+	// A TTLMap must be created with NewTTLMap, and must be closed with Close to stop the sweeper.
+	TTLMap[K comparable, V any] struct {
+		m        Map[K, *ttlItem[V]]
+		tick     time.Duration
+		onExpire func(key K, value V)
+
+		mu       sync.Mutex
+		slots    [ttlWheelSize]map[K]struct{}
+		lastTick int64 // the last tick swept, guarded by mu
+
+		closeOnce sync.Once
+		done      chan struct{}
+		wg        sync.WaitGroup
+	}
+
+	ttlItem[V any] struct {
+		value V
+		// deadline is the expiry time in unix nanoseconds, 0 means it never expires.
+		deadline int64
+	}
+)
+
+// NewTTLMap returns an empty TTLMap whose sweeper runs every tick, which defaults to 1s.
+// onExpire, if not nil, is called with every entry expired, by the sweeper or by the
+// goroutine loading it; it's not called for the entries deleted or overwritten.
+func NewTTLMap[K comparable, V any](tick time.Duration, onExpire func(key K, value V)) *TTLMap[K, V] {
+	if tick <= 0 {
+		tick = time.Second
+	}
+	m := &TTLMap[K, V]{
+		tick:     tick,
+		onExpire: onExpire,
+		done:     make(chan struct{}),
+	}
+	m.lastTick = time.Now().UnixNano() / int64(tick)
+	m.wg.Add(1)
+	go m.sweep()
+
+	return m
+}
+
+func (it *ttlItem[V]) expired(now int64) bool {
+	return it.deadline != 0 && now >= it.deadline
+}
+
+func (m *TTLMap[K, V]) newItem(value V, ttl time.Duration) *ttlItem[V] {
+	it := &ttlItem[V]{value: value}
+	if ttl > 0 {
+		it.deadline = time.Now().Add(ttl).UnixNano()
+	}
+	return it
+}
+
+// tickOf returns the tick the deadline falls into, rounded up.
+func (m *TTLMap[K, V]) tickOf(deadline int64) int64 {
+	tick := int64(m.tick)
+	return (deadline + tick - 1) / tick
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no
+// value is present or it's expired. The ok result indicates whether value was found in the map.
+func (m *TTLMap[K, V]) Load(key K) (value V, ok bool) {
+	it, ok := m.m.Load(key)
+	if !ok {
+		return value, false
+	}
+	if it.expired(time.Now().UnixNano()) {
+		m.expire(key, it)
+		return value, false
+	}
+
+	return it.value, true
+}
+
+// Store sets the value for a key, which expires after ttl. It never expires if ttl <= 0.
+func (m *TTLMap[K, V]) Store(key K, value V, ttl time.Duration) {
+	it := m.newItem(value, ttl)
+	m.m.Store(key, it)
+	m.schedule(key, it)
+}
+
+// LoadOrStore returns the existing value for the key if present and not expired.
+// Otherwise, it stores and returns the given value, which expires after ttl.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *TTLMap[K, V]) LoadOrStore(key K, value V, ttl time.Duration) (actual V, loaded bool) {
+	var (
+		it      = m.newItem(value, ttl)
+		now     = time.Now().UnixNano()
+		expired *ttlItem[V]
+	)
+	m.m.Compute(key, func(old *ttlItem[V], ok bool) (*ttlItem[V], ComputeOp) {
+		expired, loaded = nil, false
+		if ok && !old.expired(now) {
+			actual, loaded = old.value, true
+			return old, CancelOp
+		}
+		if ok {
+			expired = old
+		}
+		return it, UpdateOp
+	})
+	if expired != nil && m.onExpire != nil {
+		m.onExpire(key, expired.value)
+	}
+	if loaded {
+		return actual, true
+	}
+
+	m.schedule(key, it)
+	return value, false
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any and not expired.
+// The loaded result reports whether the key was present.
+func (m *TTLMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	it, loaded := m.m.LoadAndDelete(key)
+	if !loaded || it.expired(time.Now().UnixNano()) {
+		return value, false
+	}
+
+	return it.value, true
+}
+
+// Delete deletes the value for a key.
+func (m *TTLMap[K, V]) Delete(key K) {
+	m.m.Delete(key)
+}
+
+// TTL returns the time to live left of key, it's 0 if the key never expires.
+// The ok result reports whether the key is present and not expired.
+func (m *TTLMap[K, V]) TTL(key K) (ttl time.Duration, ok bool) {
+	it, ok := m.m.Load(key)
+	if !ok {
+		return 0, false
+	}
+	if it.deadline == 0 {
+		return 0, true
+	}
+	if ttl = time.Until(time.Unix(0, it.deadline)); ttl <= 0 {
+		return 0, false
+	}
+
+	return ttl, true
+}
+
+// Range calls f sequentially for each key and value present in the map and not expired.
+// If f returns false, range stops the iteration. See Map.Range.
+func (m *TTLMap[K, V]) Range(f func(key K, value V) bool) {
+	now := time.Now().UnixNano()
+	m.m.Range(func(key K, it *ttlItem[V]) bool {
+		if it.expired(now) {
+			return true
+		}
+		return f(key, it.value)
+	})
+}
+
+// Len returns the number of keys in the map, which may include the expired ones not swept yet.
+func (m *TTLMap[K, V]) Len() int {
+	return m.m.Len()
+}
+
+// Close stops the sweeper, the expired entries are still deleted lazily on Load after it.
+// It's safe to call Close more than once.
+func (m *TTLMap[K, V]) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	m.wg.Wait()
+}
+
+// expire deletes key if it's still the expired item, and calls onExpire.
+func (m *TTLMap[K, V]) expire(key K, it *ttlItem[V]) {
+	var deleted bool
+	m.m.Compute(key, func(old *ttlItem[V], ok bool) (*ttlItem[V], ComputeOp) {
+		// fn may be called again when racing with other writes, the last call counts.
+		deleted = ok && old == it
+		if deleted {
+			return nil, DeleteOp
+		}
+		return old, CancelOp
+	})
+	if deleted && m.onExpire != nil {
+		m.onExpire(key, it.value)
+	}
+}
+
+// schedule adds key to the slot of the wheel its item expires in.
+func (m *TTLMap[K, V]) schedule(key K, it *ttlItem[V]) {
+	if it.deadline == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	tick := m.tickOf(it.deadline)
+	if tick <= m.lastTick {
+		// its tick is swept, take the next one rather than waiting a round of the wheel
+		tick = m.lastTick + 1
+	}
+	slot := tick % ttlWheelSize
+	if m.slots[slot] == nil {
+		m.slots[slot] = make(map[K]struct{})
+	}
+	m.slots[slot][key] = struct{}{}
+	m.mu.Unlock()
+}
+
+// sweep expires the entries due in every tick, until the map is closed.
+func (m *TTLMap[K, V]) sweep() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.sweepUntil(now.UnixNano())
+		}
+	}
+}
+
+// sweepUntil sweeps the slots of the ticks passed since the last sweep,
+// a slow sweep may let the ticker drop ticks.
+func (m *TTLMap[K, V]) sweepUntil(now int64) {
+	// the deadlines in the slot of a tick are at most the start of the tick, so they're all due
+	current := now / int64(m.tick)
+	m.mu.Lock()
+	from := m.lastTick + 1
+	m.mu.Unlock()
+	if current-from >= ttlWheelSize {
+		from = current - ttlWheelSize + 1
+	}
+	for tick := from; tick <= current; tick++ {
+		m.sweepSlot(tick, now)
+	}
+}
+
+func (m *TTLMap[K, V]) sweepSlot(tick, now int64) {
+	slot := tick % ttlWheelSize
+	m.mu.Lock()
+	keys := m.slots[slot]
+	m.slots[slot] = nil
+	m.lastTick = tick
+	m.mu.Unlock()
+
+	for key := range keys {
+		it, ok := m.m.Load(key)
+		switch {
+		case !ok || it.deadline == 0:
+			// deleted, or overwritten by an item that never expires
+		case it.expired(now):
+			m.expire(key, it)
+		case m.tickOf(it.deadline)%ttlWheelSize == slot:
+			// due in a later round of the wheel
+			m.schedule(key, it)
+		}
+		// otherwise it's overwritten, and scheduled in another slot
+	}
+}
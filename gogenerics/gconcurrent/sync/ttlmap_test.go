@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTLMap(t *testing.T) {
+	m := NewTTLMap[string, int](time.Hour, nil)
+	defer m.Close()
+
+	m.Store("forever", 1, 0)
+	m.Store("short", 2, 10*time.Millisecond)
+	if v, ok := m.Load("short"); !ok || v != 2 {
+		t.Fatalf("Load(short) = %v, %v; want 2, true", v, ok)
+	}
+	if ttl, ok := m.TTL("short"); !ok || ttl <= 0 || ttl > 10*time.Millisecond {
+		t.Fatalf("TTL(short) = %v, %v", ttl, ok)
+	}
+	if ttl, ok := m.TTL("forever"); !ok || ttl != 0 {
+		t.Fatalf("TTL(forever) = %v, %v; want 0, true", ttl, ok)
+	}
+	if v, loaded := m.LoadOrStore("short", 3, 0); !loaded || v != 2 {
+		t.Fatalf("LoadOrStore(short) = %v, %v; want 2, true", v, loaded)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	// the sweeper is far away, short expires lazily
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d; want 2", n)
+	}
+	m.Range(func(key string, value int) bool {
+		if key != "forever" {
+			t.Fatalf("Range visited expired key %q", key)
+		}
+		return true
+	})
+	if _, ok := m.Load("short"); ok {
+		t.Fatalf("Load(short) found an expired key")
+	}
+	if n := m.Len(); n != 1 {
+		t.Fatalf("Len() = %d; want 1", n)
+	}
+
+	m.Store("short", 4, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if v, loaded := m.LoadOrStore("short", 5, 0); loaded || v != 5 {
+		t.Fatalf("LoadOrStore(short) = %v, %v; want 5, false", v, loaded)
+	}
+	if v, loaded := m.LoadAndDelete("short"); !loaded || v != 5 {
+		t.Fatalf("LoadAndDelete(short) = %v, %v; want 5, true", v, loaded)
+	}
+}
+
+func TestTTLMapSweep(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		expired = make(map[int]int)
+	)
+	m := NewTTLMap[int, int](5*time.Millisecond, func(key, value int) {
+		mu.Lock()
+		expired[key] = value
+		mu.Unlock()
+	})
+	defer m.Close()
+
+	for i := 0; i < 100; i++ {
+		m.Store(i, i*i, time.Duration(1+i%5)*10*time.Millisecond)
+	}
+	m.Store(100, 0, 0)
+	// overwritten before its deadline, it's not expired with the first item
+	m.Store(0, -1, time.Hour)
+	m.Delete(1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Len() > 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Len() = %d after 5s; want 2", m.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 98 {
+		t.Fatalf("%d entries expired; want 98", len(expired))
+	}
+	for i := 2; i < 100; i++ {
+		if expired[i] != i*i {
+			t.Fatalf("expired[%d] = %d; want %d", i, expired[i], i*i)
+		}
+	}
+	if v, ok := m.Load(0); !ok || v != -1 {
+		t.Fatalf("Load(0) = %v, %v; want -1, true", v, ok)
+	}
+}
+
+func TestTTLMapExpireOnce(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	m := NewTTLMap[string, int](time.Millisecond, func(key string, value int) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	})
+	m.Store("k", 1, 5*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				m.Load("k")
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+	m.Close()
+	m.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("onExpire called %d times; want 1", calls)
+	}
+}
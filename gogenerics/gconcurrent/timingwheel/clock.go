@@ -0,0 +1,116 @@
+package timingwheel
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Clock is the source of time of a TimingWheel, it's injectable for tests.
+	Clock interface {
+		Now() time.Time
+		NewTicker(d time.Duration) Ticker
+	}
+
+	// Ticker delivers ticks of a Clock, like time.Ticker.
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+
+	systemClock struct{}
+
+	systemTicker struct {
+		*time.Ticker
+	}
+
+	// FakeClock is a Clock for tests, its time only moves on Advance.
+	FakeClock struct {
+		mu      sync.Mutex
+		now     time.Time
+		tickers map[*fakeTicker]struct{}
+	}
+
+	fakeTicker struct {
+		clock  *FakeClock
+		period time.Duration
+		next   time.Time
+		c      chan time.Time
+	}
+)
+
+// SystemClock is the Clock of the real time.
+var SystemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// NewFakeClock returns a FakeClock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:     now,
+		tickers: make(map[*fakeTicker]struct{}),
+	}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a Ticker which ticks as Advance moves the clock.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		c:      make(chan time.Time, 1),
+	}
+	c.tickers[t] = struct{}{}
+	return t
+}
+
+// Advance moves the clock forward by d, and ticks the tickers due.
+// Like time.Ticker, a ticker drops the ticks its reader is too slow for.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for t := range c.tickers {
+		if t.next.After(c.now) {
+			continue
+		}
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.period)
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	delete(t.clock.tickers, t)
+	t.clock.mu.Unlock()
+}
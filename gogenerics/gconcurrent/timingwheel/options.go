@@ -0,0 +1,72 @@
+package timingwheel
+
+import "time"
+
+const (
+	defaultTick      = 10 * time.Millisecond
+	defaultWheelSize = 64
+	defaultLevels    = 4
+)
+
+type (
+	// Option customizes a TimingWheel.
+	Option func(opts *options)
+
+	options struct {
+		tick      time.Duration
+		wheelSize int
+		levels    int
+		clock     Clock
+	}
+)
+
+func buildOptions(opts ...Option) options {
+	options := options{
+		tick:      defaultTick,
+		wheelSize: defaultWheelSize,
+		levels:    defaultLevels,
+		clock:     SystemClock,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// WithTick customizes the tick of the wheel, which is the resolution of the timers. It defaults to 10ms.
+func WithTick(tick time.Duration) Option {
+	return func(opts *options) {
+		if tick > 0 {
+			opts.tick = tick
+		}
+	}
+}
+
+// WithWheelSize customizes the number of buckets of every level, it defaults to 64.
+// The wheel covers tick * size^levels without cascading the timers beyond it more than once per round.
+func WithWheelSize(size int) Option {
+	return func(opts *options) {
+		if size >= 2 {
+			opts.wheelSize = size
+		}
+	}
+}
+
+// WithLevels customizes the number of levels of the wheel, it defaults to 4.
+func WithLevels(levels int) Option {
+	return func(opts *options) {
+		if levels >= 1 {
+			opts.levels = levels
+		}
+	}
+}
+
+// WithClock customizes the clock of the wheel, it defaults to SystemClock.
+func WithClock(clock Clock) Option {
+	return func(opts *options) {
+		if clock != nil {
+			opts.clock = clock
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package timingwheel provides a hierarchical timing wheel, which schedules
+// a large number of timers far cheaper than time.AfterFunc.
+package timingwheel
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/miniLCT/gosb/gogenerics/gcontainers/glist"
+)
+
+type (
+	// TimingWheel is a hierarchical timing wheel: every level is a ring of buckets,
+	// a bucket of level i holds the timers due in the same tick * size^i span,
+	// and is cascaded to the lower levels when the span comes.
+	//
+	// Scheduling and stopping a timer are O(1), and the wheel wakes up once per tick
+	// regardless of the number of timers. The timers fire with the resolution of the tick,
+	// never earlier than their delay.
+	//
+	// A TimingWheel must be created with New, and must be stopped with Stop.
+	TimingWheel struct {
+		opts  options
+		start time.Time
+		// spans[i] is the ticks covered by a bucket of level i,
+		// spans[levels] is the ticks covered by the whole wheel.
+		spans []int64
+
+		mu      sync.Mutex
+		current int64 // the ticks passed since start
+		buckets [][]glist.List[*Timer]
+		count   int
+
+		stopOnce sync.Once
+		done     chan struct{}
+		wg       sync.WaitGroup
+	}
+
+	// Timer is the handle of a scheduled fn.
+	Timer struct {
+		w          *TimingWheel
+		fn         func()
+		expiration int64 // in ticks since the start of the wheel
+		bucket     *glist.List[*Timer]
+		elem       *glist.Element[*Timer]
+	}
+)
+
+// New returns a started TimingWheel.
+func New(opts ...Option) *TimingWheel {
+	options := buildOptions(opts...)
+	w := &TimingWheel{
+		opts:    options,
+		start:   options.clock.Now(),
+		spans:   make([]int64, options.levels+1),
+		buckets: make([][]glist.List[*Timer], options.levels),
+		done:    make(chan struct{}),
+	}
+	size := int64(options.wheelSize)
+	w.spans[0] = 1
+	for i := 1; i <= options.levels; i++ {
+		if w.spans[i-1] > math.MaxInt64/size {
+			w.spans[i] = math.MaxInt64
+		} else {
+			w.spans[i] = w.spans[i-1] * size
+		}
+	}
+	for i := range w.buckets {
+		w.buckets[i] = make([]glist.List[*Timer], options.wheelSize)
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+// Schedule calls fn in its own goroutine after delay, and returns the Timer to cancel it.
+func (w *TimingWheel) Schedule(delay time.Duration, fn func()) *Timer {
+	return w.ScheduleAt(w.opts.clock.Now().Add(delay), fn)
+}
+
+// ScheduleAt calls fn in its own goroutine at t, and returns the Timer to cancel it.
+// It's handy for the entries with deadlines, e.g. the entries of a cache or a delay queue.
+func (w *TimingWheel) ScheduleAt(t time.Time, fn func()) *Timer {
+	timer := &Timer{w: w, fn: fn}
+	w.mu.Lock()
+	w.addLocked(timer, w.ticksOf(t))
+	w.mu.Unlock()
+
+	return timer
+}
+
+// Len returns the number of timers not fired or stopped yet.
+func (w *TimingWheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Stop stops the wheel, the pending timers never fire after it.
+// It's safe to call Stop more than once.
+func (w *TimingWheel) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+	w.wg.Wait()
+}
+
+// Stop prevents the Timer from firing.
+// It returns true if the call stops the timer, false if the timer has already fired or been stopped.
+func (t *Timer) Stop() bool {
+	t.w.mu.Lock()
+	defer t.w.mu.Unlock()
+	return t.w.removeLocked(t)
+}
+
+// Reset changes the timer to fire after delay, whether it has fired or not.
+// It returns true if the timer had been active, false if the timer had fired or been stopped.
+func (t *Timer) Reset(delay time.Duration) bool {
+	w := t.w
+	expiration := w.ticksOf(w.opts.clock.Now().Add(delay))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	active := w.removeLocked(t)
+	w.addLocked(t, expiration)
+	return active
+}
+
+// ticksOf returns the tick t falls into, rounded up, so the timers never fire early.
+func (w *TimingWheel) ticksOf(t time.Time) int64 {
+	d := t.Sub(w.start)
+	if d <= 0 {
+		return 0
+	}
+	tick := w.opts.tick
+	return int64((d + tick - 1) / tick)
+}
+
+// addLocked adds t to the bucket of its expiration, or fires it if it's due.
+func (w *TimingWheel) addLocked(t *Timer, expiration int64) {
+	t.expiration = expiration
+	delta := expiration - w.current
+	if delta <= 0 {
+		go t.fn()
+		return
+	}
+
+	// the lowest level whose ring covers the delay, the timers beyond the wheel
+	// take the top level and are cascaded again until they're in range.
+	level := 0
+	for level < w.opts.levels-1 && delta >= w.spans[level+1] {
+		level++
+	}
+	bucket := &w.buckets[level][(expiration/w.spans[level])%int64(w.opts.wheelSize)]
+	t.bucket = bucket
+	t.elem = bucket.PushBack(t)
+	w.count++
+}
+
+func (w *TimingWheel) removeLocked(t *Timer) bool {
+	if t.bucket == nil {
+		return false
+	}
+
+	t.bucket.Remove(t.elem)
+	t.bucket, t.elem = nil, nil
+	w.count--
+	return true
+}
+
+func (w *TimingWheel) run() {
+	defer w.wg.Done()
+
+	ticker := w.opts.clock.NewTicker(w.opts.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case now := <-ticker.C():
+			w.advanceTo(int64(now.Sub(w.start) / w.opts.tick))
+		}
+	}
+}
+
+// advanceTo moves the wheel tick by tick up to target, cascading and firing the timers due.
+func (w *TimingWheel) advanceTo(target int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	size := int64(w.opts.wheelSize)
+	for w.current < target {
+		if w.count == 0 {
+			// nothing to cascade or fire on the way
+			w.current = target
+			return
+		}
+		w.current++
+
+		// cascade from the top down, so a timer cascaded twice in the same tick
+		// lands in a bucket that's not cascaded yet.
+		top := 0
+		for top+1 < w.opts.levels && w.current%w.spans[top+1] == 0 {
+			top++
+		}
+		for level := top; level >= 1; level-- {
+			w.cascadeLocked(&w.buckets[level][(w.current/w.spans[level])%size])
+		}
+		w.cascadeLocked(&w.buckets[0][w.current%size])
+	}
+}
+
+// cascadeLocked takes all the timers of bucket, and adds them again to fire or move them lower.
+// The timers beyond the wheel may be added back to the same bucket, so it's emptied first.
+func (w *TimingWheel) cascadeLocked(bucket *glist.List[*Timer]) {
+	if bucket.Len() == 0 {
+		return
+	}
+
+	timers := make([]*Timer, 0, bucket.Len())
+	for e := bucket.Front(); e != nil; e = e.Next() {
+		timers = append(timers, e.Value)
+	}
+	for _, t := range timers {
+		w.removeLocked(t)
+		w.addLocked(t, t.expiration)
+	}
+}
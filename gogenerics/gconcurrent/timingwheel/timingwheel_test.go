@@ -0,0 +1,128 @@
+package timingwheel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitFor waits up to a second for cond.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func TestTimingWheel(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, levels := range []int{1, 2, 3} {
+		clock := NewFakeClock(time.Unix(0, 0))
+		w := New(WithClock(clock), WithTick(time.Millisecond), WithWheelSize(4), WithLevels(levels))
+
+		// beyond the 4^levels ticks of the wheel too
+		const timers = 200
+		var fired int64
+		for i := 1; i <= timers; i++ {
+			deadline := clock.Now().Add(time.Duration(i) * time.Millisecond)
+			w.Schedule(time.Duration(i)*time.Millisecond, func() {
+				assert.False(clock.Now().Before(deadline), "fired early")
+				atomic.AddInt64(&fired, 1)
+			})
+		}
+		assert.Equal(timers, w.Len())
+
+		for i := 1; i <= timers; i++ {
+			clock.Advance(time.Millisecond)
+			w.advanceTo(int64(i))
+			// every timer fires in its own tick
+			assert.True(waitFor(func() bool {
+				return atomic.LoadInt64(&fired) == int64(i)
+			}), "levels %d tick %d: fired %d", levels, i, atomic.LoadInt64(&fired))
+		}
+		assert.Equal(0, w.Len())
+		w.Stop()
+	}
+}
+
+func TestTimerStopAndReset(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	w := New(WithClock(clock), WithTick(time.Millisecond), WithWheelSize(8), WithLevels(2))
+	defer w.Stop()
+
+	var stopped, reset, fired int64
+	s := w.Schedule(5*time.Millisecond, func() { atomic.AddInt64(&stopped, 1) })
+	r := w.Schedule(5*time.Millisecond, func() { atomic.AddInt64(&reset, 1) })
+	f := w.Schedule(time.Millisecond, func() { atomic.AddInt64(&fired, 1) })
+	assert.True(s.Stop())
+	assert.False(s.Stop())
+	assert.True(r.Reset(20 * time.Millisecond))
+
+	clock.Advance(10 * time.Millisecond)
+	w.advanceTo(10)
+	assert.True(waitFor(func() bool { return atomic.LoadInt64(&fired) == 1 }))
+	assert.False(f.Stop())
+	assert.Equal(int64(0), atomic.LoadInt64(&stopped))
+	assert.Equal(int64(0), atomic.LoadInt64(&reset))
+
+	clock.Advance(10 * time.Millisecond)
+	w.advanceTo(20)
+	assert.True(waitFor(func() bool { return atomic.LoadInt64(&reset) == 1 }))
+
+	// a fired timer is scheduled again by Reset
+	assert.False(f.Reset(0))
+	assert.True(waitFor(func() bool { return atomic.LoadInt64(&fired) == 2 }))
+	assert.Equal(0, w.Len())
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	w := New(WithClock(clock), WithTick(time.Millisecond))
+	defer w.Stop()
+
+	done := make(chan struct{})
+	w.ScheduleAt(time.Unix(1, 0), func() { close(done) })
+
+	// the wheel follows the ticks of the clock
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-done:
+		assert.Fail("fired early")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail("not fired")
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	assert := assert.New(t)
+
+	w := New(WithTick(time.Millisecond))
+	defer w.Stop()
+
+	start := time.Now()
+	done := make(chan time.Duration, 1)
+	w.Schedule(20*time.Millisecond, func() { done <- time.Since(start) })
+	select {
+	case elapsed := <-done:
+		assert.GreaterOrEqual(elapsed, 20*time.Millisecond)
+	case <-time.After(time.Second):
+		assert.Fail("not fired")
+	}
+	w.Stop()
+}